@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+const (
+	heatmapWeeks = 26
+	dayFormat    = "2006-01-02"
+)
+
+// 256-color palette indexes, from no reviews to the most reviews in a day
+var heatmapColors = []uint8{237, 22, 28, 34, 46}
+
+var heatmapDays = []string{"Mon", "", "Wed", "", "Fri", "", "Sun"}
+
+// Number of reviews on each day, keyed by date in local time.
+func reviewsPerDay(entries []ReviewLogEntry) map[string]int {
+	counts := make(map[string]int)
+
+	for _, entry := range entries {
+		counts[entry.Time.In(now().Location()).Format(dayFormat)]++
+	}
+
+	return counts
+}
+
+// Days in a row with at least one review. The current streak isn't broken
+// until today ends without reviews.
+func streaks(counts map[string]int) (int, int) {
+	today := startOfDay(now())
+	current := 0
+
+	day := today
+
+	if counts[day.Format(dayFormat)] == 0 {
+		day = day.AddDate(0, 0, -1)
+	}
+
+	for counts[day.Format(dayFormat)] > 0 {
+		current++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	longest := 0
+
+	for date := range counts {
+		day, err := time.ParseInLocation(dayFormat, date, today.Location())
+
+		// Only days starting a streak are followed
+		if err != nil || counts[day.AddDate(0, 0, -1).Format(dayFormat)] > 0 {
+			continue
+		}
+
+		length := 0
+
+		for counts[day.Format(dayFormat)] > 0 {
+			length++
+			day = day.AddDate(0, 0, 1)
+		}
+
+		if length > longest {
+			longest = length
+		}
+	}
+
+	return current, longest
+}
+
+// Shade of a day with the given number of reviews, 0 for none.
+func heatmapLevel(count int, max int) int {
+	if count == 0 || max == 0 {
+		return 0
+	}
+
+	levels := len(heatmapColors) - 1
+	level := (count*levels + max - 1) / max
+
+	if level > levels {
+		level = levels
+	}
+
+	return level
+}
+
+// Calendar of the last weeks, a column per week and a row per day of the week.
+func renderHeatmap(counts map[string]int, weeks int) string {
+	today := startOfDay(now())
+	weekday := (int(today.Weekday()) + 6) % 7
+	start := today.AddDate(0, 0, -weekday-(weeks-1)*7)
+
+	max := 0
+
+	for week := 0; week < weeks; week++ {
+		for day := 0; day < 7; day++ {
+			if count := counts[start.AddDate(0, 0, week*7+day).Format(dayFormat)]; count > max {
+				max = count
+			}
+		}
+	}
+
+	var heatmap strings.Builder
+
+	months := []rune(strings.Repeat(" ", weeks*2))
+	lastMonth := time.Month(0)
+	labelEnd := 0
+
+	for week := 0; week < weeks; week++ {
+		month := start.AddDate(0, 0, week*7).Month()
+
+		// Skipped if there's no room for it
+		if month != lastMonth && week*2 >= labelEnd && week*2+3 <= len(months) {
+			copy(months[week*2:], []rune(month.String()[:3]))
+			labelEnd = week*2 + 4
+		}
+
+		lastMonth = month
+	}
+
+	fmt.Fprintf(&heatmap, "\t    %s\n", strings.TrimRight(string(months), " "))
+
+	for day := 0; day < 7; day++ {
+		fmt.Fprintf(&heatmap, "\t%-3s ", heatmapDays[day])
+
+		for week := 0; week < weeks; week++ {
+			date := start.AddDate(0, 0, week*7+day)
+
+			if date.After(today) {
+				break
+			}
+
+			level := heatmapLevel(counts[date.Format(dayFormat)], max)
+			heatmap.WriteString(aurora.Index(heatmapColors[level], "■ ").String())
+		}
+
+		heatmap.WriteString("\n")
+	}
+
+	heatmap.WriteString("\t    Less ")
+
+	for _, color := range heatmapColors {
+		heatmap.WriteString(aurora.Index(color, "■ ").String())
+	}
+
+	heatmap.WriteString("More\n")
+
+	return heatmap.String()
+}
+
+func printActivity(entries []ReviewLogEntry) {
+	counts := reviewsPerDay(entries)
+	current, longest := streaks(counts)
+
+	fmt.Println(aurora.Blue("Activity"))
+	fmt.Print(renderHeatmap(counts, heatmapWeeks))
+	fmt.Printf("\n\tCurrent streak: %s, longest: %s\n\n", formatDays(current), formatDays(longest))
+}
+
+func formatDays(days int) string {
+	if days == 1 {
+		return "1 day"
+	}
+
+	return fmt.Sprintf("%d days", days)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewsPerDay(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	entries := []ReviewLogEntry{
+		{Time: day},
+		{Time: day.Add(-time.Hour)},
+		{Time: day.AddDate(0, 0, -3)},
+	}
+
+	assert.Equal(t, map[string]int{"2020-05-10": 2, "2020-05-07": 1}, reviewsPerDay(entries))
+}
+
+func TestStreaks(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	counts := map[string]int{
+		"2020-04-01": 1, "2020-04-02": 3, "2020-04-03": 1, "2020-04-04": 2,
+		"2020-05-08": 1, "2020-05-09": 1,
+	}
+
+	// Today has no reviews yet, the streak goes on until it ends
+	current, longest := streaks(counts)
+	assert.Equal(t, 2, current)
+	assert.Equal(t, 4, longest)
+
+	counts["2020-05-10"] = 1
+	current, _ = streaks(counts)
+	assert.Equal(t, 3, current)
+
+	delete(counts, "2020-05-09")
+	current, _ = streaks(counts)
+	assert.Equal(t, 1, current)
+
+	current, longest = streaks(map[string]int{})
+	assert.Equal(t, 0, current)
+	assert.Equal(t, 0, longest)
+}
+
+func TestHeatmapLevel(t *testing.T) {
+	assert.Equal(t, 0, heatmapLevel(0, 10))
+	assert.Equal(t, 1, heatmapLevel(1, 10))
+	assert.Equal(t, 2, heatmapLevel(5, 10))
+	assert.Equal(t, 4, heatmapLevel(10, 10))
+	assert.Equal(t, 0, heatmapLevel(0, 0))
+}
+
+func TestRenderHeatmap(t *testing.T) {
+	// A Wednesday
+	day := time.Date(2020, 5, 13, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	lines := strings.Split(strings.TrimRight(renderHeatmap(map[string]int{"2020-05-13": 4}, 4), "\n"), "\n")
+
+	assert.Len(t, lines, 9)
+	assert.Equal(t, "\t    Apr May", lines[0])
+	assert.Equal(t, 4, strings.Count(lines[1], "■"))
+	assert.Equal(t, 4, strings.Count(lines[3], "■"))
+	// Thursday hasn't come yet
+	assert.Equal(t, 3, strings.Count(lines[4], "■"))
+	assert.Contains(t, lines[3], "38;5;46m")
+}
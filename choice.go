@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// Number of options shown in choice mode, the correct answer included
+const (
+	minChoices = 4
+	maxChoices = 6
+)
+
+// Answer of a definition when it's asked in the given direction.
+func answerSide(def Definition, direction string) string {
+	from, to := def.From, def.To
+
+	// The reversed half of a bidirectional card is stored the other way round
+	if def.Direction == directionReversed {
+		from, to = to, from
+	}
+
+	if direction == directionReversed {
+		return from
+	}
+
+	return to
+}
+
+type distractor struct {
+	text  string
+	score float64
+}
+
+// How much a wrong option looks like the correct answer: from the same box, of similar length
+// and starting with the same letters.
+func similarity(text string, correct string, sameBox bool) float64 {
+	score := 0.0
+
+	if sameBox {
+		score++
+	}
+
+	a := []rune(strings.ToLower(text))
+	b := []rune(strings.ToLower(correct))
+
+	score += 1 - math.Abs(float64(len(a)-len(b)))/math.Max(float64(len(a)), float64(len(b)))
+
+	prefix := 0
+
+	for prefix < len(a) && prefix < len(b) && prefix < 3 && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	return score + float64(prefix)/3
+}
+
+// Wrong options for a question, answers of other definitions in the deck that are most like the correct one.
+// They're picked at random among the most similar ones, so the options change from session to session.
+func chooseDistractors(deck *Deck, question *Question, count int) []string {
+	answers := getAlternatives(question.Answer)
+
+	// Nothing to choose, the answer is typed instead
+	if len(answers) == 0 {
+		return []string{}
+	}
+
+	scheduler := deck.scheduler()
+	level := scheduler.level(question.Definition.ID)
+	correct := plainText(answers[0])
+
+	// Options that would be correct as well, and ones already picked
+	excluded := make(map[string]bool)
+
+	for _, alternative := range answers {
+		excluded[strings.ToLower(normalizeSpaces(plainText(alternative)))] = true
+	}
+
+	card := strings.TrimSuffix(question.Definition.ID, reversedIDSuffix)
+	candidates := []distractor{}
+
+	for _, def := range deck.Definitions {
+		// Cloze answers only make sense for other cloze sentences
+		if def.Cloze != question.Definition.Cloze || strings.TrimSuffix(def.ID, reversedIDSuffix) == card {
+			continue
+		}
+
+		alternatives := getAlternatives(answerSide(def, question.Direction))
+
+		if len(alternatives) == 0 {
+			continue
+		}
+
+		key := strings.ToLower(normalizeSpaces(plainText(alternatives[0])))
+
+		if excluded[key] {
+			continue
+		}
+
+		excluded[key] = true
+		candidates = append(candidates, distractor{
+			text:  alternatives[0],
+			score: similarity(plainText(alternatives[0]), correct, scheduler.level(def.ID) == level),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	if len(candidates) > count*2 {
+		candidates = candidates[:count*2]
+	}
+
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+
+	if len(candidates) > count {
+		candidates = candidates[:count]
+	}
+
+	distractors := []string{}
+
+	for _, candidate := range candidates {
+		distractors = append(distractors, candidate.text)
+	}
+
+	return distractors
+}
+
+// Wrong options for choice mode, nil if the deck doesn't have enough of them to show the given number
+// of options, the answer is typed then.
+func choiceDistractors(deck *Deck, question *Question, choices int) []string {
+	distractors := chooseDistractors(deck, question, choices-1)
+
+	if len(distractors) < choices-1 {
+		return nil
+	}
+
+	return distractors
+}
+
+// Why a question in choice mode is answered by typing.
+func typedChoiceReason(choices int) string {
+	return fmt.Sprintf("Not enough other answers in the deck for %d options, type the answer", choices)
+}
+
+// The correct answer mixed in with the wrong options, and its index.
+func buildChoices(question *Question, distractors []string) ([]string, int) {
+	options := append([]string{}, distractors...)
+	correct := rand.Intn(len(options) + 1)
+
+	options = append(options, "")
+	copy(options[correct+1:], options[correct:])
+	options[correct] = firstAlternative(question.Answer)
+
+	return options, correct
+}
+
+// Show the options and read the number of one of them. Returns false if the input is closed.
+func readChoice(input *bufio.Scanner, options []string) (int, bool) {
+	for i, option := range options {
+		lines := strings.Split(renderMarkup(option), "\n")
+		fmt.Printf("  %d) %s\n", i+1, strings.Join(lines, "\n     "))
+	}
+
+	for {
+		fmt.Printf("\n%s (1-%d):\n", aurora.Yellow("Answer"), len(options))
+
+		if !input.Scan() {
+			return 0, false
+		}
+
+		number, err := strconv.Atoi(strings.TrimSpace(input.Text()))
+
+		if err == nil && number >= 1 && number <= len(options) {
+			return number - 1, true
+		}
+	}
+}
+
+// Ask to pick the correct option, at the given index. Returns the picked option and whether it's correct,
+// false if the input is closed.
+func askChoice(input *bufio.Scanner, options []string, correct int) (string, Match, bool) {
+	picked, ok := readChoice(input, options)
+
+	if !ok {
+		return "", matchWrong, false
+	}
+
+	match, feedback := describeChoice(options, picked, correct)
+	fmt.Print(feedback)
+
+	return plainText(options[picked]), match, true
+}
+
+// Whether the picked option is correct and the feedback shown for it.
+func describeChoice(options []string, picked int, correct int) (Match, string) {
+	if picked == correct {
+		return matchExact, fmt.Sprintf("\n%s\n\n", aurora.Green("============ CORRECT ============"))
+	}
+
+	return matchWrong, fmt.Sprintf("\n%s\n\n%s:\n%d) %s\n\n",
+		aurora.Red("============ WRONG ============"), aurora.Blue("Correct answer"), correct+1, renderMarkup(options[correct]))
+}
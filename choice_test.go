@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnswerSide(t *testing.T) {
+	def := Definition{From: "andare", To: "to go"}
+	reversed := Definition{From: "to go", To: "andare", Direction: directionReversed}
+
+	assert.Equal(t, "to go", answerSide(def, directionForward))
+	assert.Equal(t, "andare", answerSide(def, directionReversed))
+	assert.Equal(t, "to go", answerSide(reversed, directionForward))
+	assert.Equal(t, "andare", answerSide(reversed, directionReversed))
+}
+
+func TestSimilarity(t *testing.T) {
+	assert.Greater(t, similarity("to see", "to go", false), similarity("always", "to go", false))
+	assert.Greater(t, similarity("to be", "to go", true), similarity("to be", "to go", false))
+	assert.Greater(t, similarity("andiamo", "andare", false), similarity("vediamo", "andare", false))
+}
+
+func TestChooseDistractors(t *testing.T) {
+	data := `
+        [(andare) (to go | to walk)]
+        [(camminare) (to walk)]
+        [(essere) (to be) @bidirectional]
+        [(vedere) (to see)]
+        [(Io {{c1::dormo}})]
+    `
+
+	deck, err := loadDeck(data)
+	assert.Nil(t, err)
+
+	question := &Question{Definition: deck.Definitions[0], Text: "andare", Answer: "to go | to walk", Direction: directionForward}
+	distractors := chooseDistractors(deck, question, 5)
+
+	// Neither another correct answer nor a cloze gap, and both halves of a bidirectional card give the same answer
+	assert.ElementsMatch(t, []string{"to be", "to see"}, distractors)
+	assert.Len(t, chooseDistractors(deck, question, 1), 1)
+}
+
+func TestChooseDistractors_leaves_out_the_other_half_of_the_card(t *testing.T) {
+	deck, err := loadDeck("[(essere) (to be) @bidirectional] [(vedere) (to see)]")
+	assert.Nil(t, err)
+
+	question := &Question{Definition: deck.Definitions[1], Text: "to be", Answer: "essere", Direction: directionReversed}
+
+	assert.Equal(t, []string{"vedere"}, chooseDistractors(deck, question, 3))
+}
+
+func TestChooseDistractors_no_answer(t *testing.T) {
+	deck, err := loadDeck("[(andare) (to go)] [(essere) (to be)]")
+	assert.Nil(t, err)
+
+	// A side with nothing but separators is asked as a typed answer
+	question := &Question{Definition: deck.Definitions[0], Text: "andare", Answer: "|", Direction: directionForward}
+
+	assert.Empty(t, chooseDistractors(deck, question, 3))
+
+	options, correct := buildChoices(question, []string{"to be"})
+	assert.Equal(t, "|", options[correct])
+}
+
+func TestBuildChoices(t *testing.T) {
+	question := &Question{Answer: "to go | to walk"}
+
+	for i := 0; i < 20; i++ {
+		options, correct := buildChoices(question, []string{"to be", "to see", "to sleep"})
+
+		assert.Len(t, options, 4)
+		assert.Equal(t, "to go", options[correct])
+		assert.ElementsMatch(t, []string{"to go", "to be", "to see", "to sleep"}, options)
+	}
+}
+
+func TestReadChoice(t *testing.T) {
+	input := bufio.NewScanner(strings.NewReader("x\n7\n 2 \n"))
+
+	picked, ok := readChoice(input, []string{"to go", "to be", "to see", "to sleep"})
+
+	assert.True(t, ok)
+	assert.Equal(t, 1, picked)
+
+	_, ok = readChoice(bufio.NewScanner(strings.NewReader("")), []string{"to go", "to be"})
+
+	assert.False(t, ok)
+}
+
+func TestAskChoice(t *testing.T) {
+	options := []string{"to be", "**to go**"}
+
+	answer, match, ok := askChoice(bufio.NewScanner(strings.NewReader("2\n")), options, 1)
+
+	assert.True(t, ok)
+	assert.Equal(t, "to go", answer)
+	assert.Equal(t, matchExact, match)
+
+	answer, match, ok = askChoice(bufio.NewScanner(strings.NewReader("1\n")), options, 1)
+
+	assert.True(t, ok)
+	assert.Equal(t, "to be", answer)
+	assert.Equal(t, matchWrong, match)
+
+	_, _, ok = askChoice(bufio.NewScanner(strings.NewReader("")), options, 1)
+
+	assert.False(t, ok)
+}
+
+func TestChoiceDistractors(t *testing.T) {
+	deck, err := loadDeck("[(andare) (to go)] [(essere) (to be)] [(vedere) (to see)] [(dormire) (to sleep)]")
+	assert.Nil(t, err)
+
+	question := &Question{Definition: deck.Definitions[0], Text: "andare", Answer: "to go", Direction: directionForward}
+
+	assert.Len(t, choiceDistractors(deck, question, 4), 3)
+
+	// Every option is shown or it's typed
+	assert.Nil(t, choiceDistractors(deck, question, 5))
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A gap in a cloze sentence, "{{c1::vado}}" or with a hint "{{c1::vado::andare}}". It can span lines.
+var clozePattern = regexp.MustCompile(`(?s)\{\{c(\d+)::(.+?)(?:::(.+?))?\}\}`)
+
+func hasCloze(text string) bool {
+	return clozePattern.MatchString(text)
+}
+
+// Gaps with nothing to answer, only blanks or separators of alternatives, e.g. "{{c2:: | }}".
+func emptyGaps(text string) []string {
+	gaps := []string{}
+
+	for _, match := range clozePattern.FindAllStringSubmatch(text, -1) {
+		if len(getAlternatives(match[2])) == 0 {
+			gaps = append(gaps, match[0])
+		}
+	}
+
+	return gaps
+}
+
+// A cloze sentence becomes a definition per gap number: the sentence with the gaps of that number
+// blanked, to be answered with their text. Other gaps are shown filled in.
+// IDs are derived from the note's ID, or its text, and the gap number, e.g. "verbs-1-c2".
+// Empty gaps are left out, parsing reports them.
+func expandCloze(text string, id string) []Definition {
+	if id == "" {
+		id = contentID(text)
+	}
+
+	numbers := []int{}
+	seen := make(map[int]bool)
+
+	for _, match := range clozePattern.FindAllStringSubmatch(text, -1) {
+		number, _ := strconv.Atoi(match[1])
+
+		if !seen[number] && len(getAlternatives(match[2])) > 0 {
+			seen[number] = true
+			numbers = append(numbers, number)
+		}
+	}
+
+	sort.Ints(numbers)
+
+	definitions := []Definition{}
+
+	for _, number := range numbers {
+		answers := [][]string{}
+
+		question := clozePattern.ReplaceAllStringFunc(text, func(gap string) string {
+			match := clozePattern.FindStringSubmatch(gap)
+			alternatives := getAlternatives(match[2])
+
+			if len(alternatives) == 0 {
+				return ""
+			}
+
+			if match[1] != strconv.Itoa(number) {
+				return escapeAlternative(alternatives[0])
+			}
+
+			answers = append(answers, alternatives)
+
+			hint := "..."
+
+			if match[3] != "" {
+				hint = match[3]
+			}
+
+			return fmt.Sprintf("==[%s]==", hint)
+		})
+
+		definitions = append(definitions, Definition{
+			ID:    fmt.Sprintf("%s-c%d", id, number),
+			From:  question,
+			To:    combineGapAnswers(answers),
+			Cloze: true,
+		})
+	}
+
+	return definitions
+}
+
+// Several gaps with the same number are answered together, in order, with any alternative of each of them.
+func combineGapAnswers(gaps [][]string) string {
+	combinations := []string{""}
+
+	for _, alternatives := range gaps {
+		next := []string{}
+
+		for _, combination := range combinations {
+			for _, alternative := range alternatives {
+				next = append(next, strings.TrimSpace(combination+" "+escapeAlternative(alternative)))
+			}
+		}
+
+		combinations = next
+	}
+
+	return strings.Join(combinations, " "+string(alternativesSeparator)+" ")
+}
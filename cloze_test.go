@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasCloze(t *testing.T) {
+	assert.True(t, hasCloze("Io {{c1::vado}} a casa"))
+	assert.False(t, hasCloze("Io vado a casa"))
+	assert.False(t, hasCloze("Io {{vado}} a casa"))
+}
+
+func TestExpandCloze(t *testing.T) {
+	definitions := expandCloze("{{c2::Io}} {{c1::vado|ci vado}} a {{c1::casa::dove?}}", "")
+	id := contentID("{{c2::Io}} {{c1::vado|ci vado}} a {{c1::casa::dove?}}")
+
+	assert.Equal(t, []Definition{
+		{ID: id + "-c1", From: "Io ==[...]== a ==[dove?]==", To: "vado casa | ci vado casa", Cloze: true},
+		{ID: id + "-c2", From: "==[...]== vado a casa", To: "Io", Cloze: true},
+	}, definitions)
+
+	// Escaped pipes stay escaped in the question and the answer
+	definitions = expandCloze(`{{c1::a \| b}} {{c2::c \| d}}`, "pipes")
+
+	assert.Equal(t, `==[...]== c \| d`, definitions[0].From)
+	assert.Equal(t, `a \| b`, definitions[0].To)
+	assert.Equal(t, []string{"a | b"}, getAlternatives(definitions[0].To))
+}
+
+func TestExpandCloze_empty_gap(t *testing.T) {
+	text := "Io {{c1::vado}} a {{c2:: | }}"
+
+	assert.Equal(t, []string{"{{c2:: | }}"}, emptyGaps(text))
+	assert.Equal(t, []Definition{
+		{ID: "vado-c1", From: "Io ==[...]== a ", To: "vado", Cloze: true},
+	}, expandCloze(text, "vado"))
+
+	_, err := loadDeck("[(" + text + ")]")
+	assert.NotNil(t, err)
+}
+
+func TestLoadDeck_multiline_gap(t *testing.T) {
+	data := `[ @id vado
+    (
+        {{c1::io vado
+        tu vai}} al lavoro
+    )
+]`
+
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Definition{
+		{ID: "vado-c1", From: "==[...]== al lavoro", To: "io vado\ntu vai", Cloze: true},
+	}, deck.Definitions)
+
+	match, _ := describeAnswer("io vado tu vai", deck.Definitions[0].To, Matcher{})
+	assert.Equal(t, matchExact, match)
+}
+
+func TestLoadDeck_cloze(t *testing.T) {
+	data := `
+        [(andare) (to go)]
+        [ @id vado (Io {{c1::vado}} a {{c2::casa}}) ]
+    `
+
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
+	assert.Len(t, deck.Definitions, 3)
+	assert.Equal(t, Definition{ID: "vado-c1", From: "Io ==[...]== a casa", To: "vado", Cloze: true}, deck.Definitions[1])
+	assert.Equal(t, "vado-c2", deck.Definitions[2].ID)
+}
+
+func TestGetQuestionAnswer_cloze_is_never_reversed(t *testing.T) {
+	def := Definition{From: "Io ==[...]== a casa", To: "vado", Cloze: true}
+
+	question, answer, direction := getQuestionAnswer(getCommand("reversed"), &def)
+
+	assert.Equal(t, "Io ==[...]== a casa", question)
+	assert.Equal(t, "vado", answer)
+	assert.Equal(t, directionForward, direction)
+}
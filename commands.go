@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type Command struct {
+	Name        string
+	Description string
+	Run         func(args []string) error
+}
+
+var commands []Command
+
+// Filled in here, commands refer back to the list when printing their usage.
+func init() {
+	commands = []Command{
+		{"study", "Study the cards that are due (the default command)", studyCommand},
+		{"convert", "Convert a file of question=answer lines to a deck", convertCommand},
+		{"stats", "Show progress, accuracy and what's coming due", statsCommand},
+		{"list", "List cards with their box and due date", listCommand},
+		{"add", "Add a card to a deck", addCommand},
+		{"validate", "Check deck files for malformed cards, duplicates and unbalanced brackets", validateCommand},
+		{"export", "Write the cards of a deck as CSV, JSON or question=answer lines", exportCommand},
+		{"optimize", "Fit FSRS weights to the deck's reviews", optimizeCommand},
+		{"restore", "List history backups or roll back to one", restoreCommand},
+	}
+}
+
+func findCommand(name string) *Command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+
+	return nil
+}
+
+func isHelpFlag(arg string) bool {
+	return arg == "-h" || arg == "-help" || arg == "--help" || arg == "help"
+}
+
+func printUsage(output io.Writer) {
+	fmt.Fprintf(output, "Usage: repetition <command> [flags]\n\nCommands:\n")
+
+	for _, command := range commands {
+		fmt.Fprintf(output, "  %-10s %s\n", command.Name, command.Description)
+	}
+
+	fmt.Fprintf(output, "\nRun 'repetition <command> -help' for the flags of a command.\n")
+}
+
+// Flags of a command, with a usage message naming it.
+func newFlagSet(name string, arguments string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: repetition %s %s\n\n", name, arguments)
+
+		if command := findCommand(name); command != nil {
+			fmt.Fprintf(flags.Output(), "%s.\n\n", command.Description)
+		}
+
+		fmt.Fprintf(flags.Output(), "Flags:\n")
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// Runs the command named by the first argument. Flags without a command start
+// a study session, as they did before there were commands.
+func runCommand(args []string) error {
+	if len(args) > 0 && isHelpFlag(args[0]) {
+		printUsage(os.Stdout)
+		return nil
+	}
+
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return studyCommand(args)
+	}
+
+	command := findCommand(args[0])
+
+	if command == nil {
+		printUsage(os.Stderr)
+		return fmt.Errorf("unknown command '%s'", args[0])
+	}
+
+	return command.Run(args[1:])
+}
+
+func readDeckFile(deckPath string) (*Deck, error) {
+	if deckPath == "" {
+		return nil, errors.New("no deck given, use -deck-path")
+	}
+
+	data, err := loadFile(deckPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("file '%s' does not exist", deckPath)
+	}
+
+	deck, err := loadDeck(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the deck file '%s': %s", deckPath, err)
+	}
+
+	return deck, nil
+}
+
+func listCommand(args []string) error {
+	flags := newFlagSet("list", "-deck-path <deck>")
+	deckPath := flags.String("deck-path", "", "Path to deck file")
+
+	flags.Parse(args)
+
+	deck, err := readDeckFile(*deckPath)
+
+	if err != nil {
+		return err
+	}
+
+	history, err := loadHistory(historyPath(*deckPath))
+
+	if err != nil {
+		return fmt.Errorf("cannot load the deck history file %s", err)
+	}
+
+	var scheduler Scheduler
+	level := "box"
+
+	if history != nil {
+		if history.Algorithm == "" {
+			history.Algorithm = algorithmLeitner
+		}
+
+		if history.Algorithm != algorithmLeitner {
+			level = "streak"
+		}
+
+		// Cards added to the deck file since the last session show up as new, nothing is saved
+		scheduler = history.scheduler()
+		scheduler.reconcile(deck.Definitions)
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "ID\tQuestion\tAnswer\t%s\tDue\n", strings.Title(level))
+
+	var dueDates map[string]time.Time
+
+	if scheduler != nil {
+		dueDates = scheduler.dueDates()
+	}
+
+	for _, def := range deck.Definitions {
+		cardLevel := "-"
+		due := "now"
+
+		if scheduler != nil {
+			cardLevel = fmt.Sprint(scheduler.level(def.ID))
+
+			if date := dueDates[def.ID]; !date.IsZero() {
+				due = date.Format("2006-01-02")
+			}
+		}
+
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", def.ID, formatOneLine(def.From), formatOneLine(def.To), cardLevel, due)
+	}
+
+	return writer.Flush()
+}
+
+func addCommand(args []string) error {
+	flags := newFlagSet("add", "-deck-path <deck> [-id <id>] <question> <answer> | <cloze sentence>")
+	deckPath := flags.String("deck-path", "", "Path to deck file, created if it doesn't exist")
+	id := flags.String("id", "", "Card ID, derived from the question if not set")
+
+	flags.Parse(args)
+
+	if *deckPath == "" {
+		return errors.New("no deck given, use -deck-path")
+	}
+
+	// A cloze sentence is a card on its own
+	isCloze := flags.NArg() == 1 && hasCloze(flags.Arg(0))
+
+	if flags.NArg() != 2 && !isCloze {
+		flags.Usage()
+		return errors.New("a question and an answer, or a sentence with gaps such as {{c1::text}}, are needed")
+	}
+
+	def := Definition{
+		ID:   *id,
+		From: strings.TrimSpace(flags.Arg(0)),
+		To:   strings.TrimSpace(flags.Arg(1)),
+	}
+
+	if def.From == "" || (def.To == "" && !isCloze) {
+		return errors.New("the question and the answer cannot be empty")
+	}
+
+	data, err := ioutil.ReadFile(*deckPath)
+
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if len(data) > 0 {
+		deck, err := loadDeck(string(data))
+
+		if err != nil {
+			return fmt.Errorf("cannot load the deck file '%s': %s", *deckPath, err)
+		}
+
+		for _, existing := range deck.Definitions {
+			if contentID(existing.From) == contentID(def.From) || (def.ID != "" && existing.ID == def.ID) {
+				return fmt.Errorf("'%s' is already in the deck", existing.From)
+			}
+		}
+	}
+
+	entry := formatDeckEntry(def) + "\n"
+
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		entry = "\n" + entry
+	}
+
+	file, err := os.OpenFile(*deckPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(entry); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Reports every problem as <deck>:<line>:<column>: <message>, fails if there's any.
+func validateCommand(args []string) error {
+	flags := newFlagSet("validate", "-deck-path <deck> [<deck>...]")
+	deckPath := flags.String("deck-path", "", "Path to deck file, a directory of decks or a glob; more decks can follow the flags")
+
+	flags.Parse(args)
+
+	patterns := flags.Args()
+
+	if *deckPath != "" {
+		patterns = append([]string{*deckPath}, patterns...)
+	}
+
+	if len(patterns) == 0 {
+		return errors.New("no deck given, use -deck-path")
+	}
+
+	paths, err := expandDeckPaths(patterns)
+
+	if err != nil {
+		return err
+	}
+
+	problems := 0
+
+	for _, path := range paths {
+		data, err := loadFile(path)
+
+		if err != nil {
+			return fmt.Errorf("file '%s' does not exist", path)
+		}
+
+		errs := lintDeck(data)
+
+		for _, err := range errs {
+			fmt.Printf("%s:%d:%d: %s\n", path, err.Line, err.Column, err.Message)
+		}
+
+		if len(errs) == 0 {
+			parsed, _ := parseDeck(data)
+			fmt.Printf("%s: %d cards, OK\n", path, len(parsed.Cards))
+		}
+
+		problems += len(errs)
+	}
+
+	if problems == 1 {
+		return errors.New("1 problem found")
+	}
+
+	if problems > 0 {
+		return fmt.Errorf("%d problems found", problems)
+	}
+
+	return nil
+}
+
+func exportCommand(args []string) error {
+	flags := newFlagSet("export", "-deck-path <deck> [-format csv|json|kv] [-output <file>]")
+	deckPath := flags.String("deck-path", "", "Path to deck file")
+	format := flags.String("format", "csv", "Output format (csv, json, kv)")
+	outputPath := flags.String("output", "", "Output file, standard output if not set")
+
+	flags.Parse(args)
+
+	deck, err := readDeckFile(*deckPath)
+
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+
+	if *outputPath != "" {
+		file, err := os.Create(*outputPath)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+		output = file
+	}
+
+	return exportDefinitions(output, deck.Definitions, *format)
+}
+
+func exportDefinitions(output io.Writer, definitions []Definition, format string) error {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(output)
+		writer.Write([]string{"id", "question", "answer"})
+
+		for _, def := range definitions {
+			writer.Write([]string{def.ID, def.From, def.To})
+		}
+
+		writer.Flush()
+
+		return writer.Error()
+	case "json":
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", " ")
+
+		return encoder.Encode(definitions)
+	case "kv":
+		// "=" separates the sides, it can't be part of them. Checked first, so nothing is written then.
+		for _, def := range definitions {
+			if strings.Contains(def.From, "=") || strings.Contains(def.To, "=") {
+				return fmt.Errorf("'%s' can't be exported as kv, it contains '='", formatOneLine(def.From))
+			}
+		}
+
+		// One line per card, so multi-line sides are joined
+		for _, def := range definitions {
+			from := strings.Join(strings.Fields(def.From), " ")
+			to := strings.Join(strings.Fields(def.To), " ")
+
+			if _, err := fmt.Fprintf(output, "%s=%s\n", from, to); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown format '%s'", format)
+}
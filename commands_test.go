@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCommand(t *testing.T) {
+	assert.Equal(t, "stats", findCommand("stats").Name)
+	assert.Nil(t, findCommand("nonexistent"))
+}
+
+func TestRunCommand_unknown_command(t *testing.T) {
+	assert.EqualError(t, runCommand([]string{"nonexistent"}), "unknown command 'nonexistent'")
+}
+
+func TestAddCommand(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	deckPath := filepath.Join(dir, "test.deck")
+	ioutil.WriteFile(deckPath, []byte("[\n    (andare)\n    (to go)\n]"), 0644)
+
+	assert.Nil(t, addCommand([]string{"-deck-path", deckPath, "essere", "to be"}))
+	assert.Nil(t, addCommand([]string{"-deck-path", deckPath, "-id", "see", "vedere", "to see"}))
+	assert.EqualError(t, addCommand([]string{"-deck-path", deckPath, "Essere", "to exist"}), "'essere' is already in the deck")
+	assert.Nil(t, addCommand([]string{"-deck-path", deckPath, "stare", "to stay (somewhere)"}))
+	assert.Nil(t, addCommand([]string{"-deck-path", deckPath, "-id", "casa", "Io vado a {{c1::casa}}"}))
+	assert.NotNil(t, addCommand([]string{"-deck-path", deckPath, "Io vado a casa"}))
+
+	deck, err := readDeckFile(deckPath)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Definition{
+		{ID: contentID("andare"), From: "andare", To: "to go"},
+		{ID: contentID("essere"), From: "essere", To: "to be"},
+		{ID: "see", From: "vedere", To: "to see"},
+		{ID: contentID("stare"), From: "stare", To: "to stay (somewhere)"},
+		{ID: "casa-c1", From: "Io vado a ==[...]==", To: "casa", Cloze: true},
+	}, deck.Definitions)
+}
+
+func TestExportDefinitions(t *testing.T) {
+	definitions := []Definition{defToGo, {ID: "a", From: "a, b", To: "c"}}
+
+	var output bytes.Buffer
+	assert.Nil(t, exportDefinitions(&output, definitions, "csv"))
+	assert.Equal(t, "id,question,answer\nandare,andare,to go\na,\"a, b\",c\n", output.String())
+
+	output.Reset()
+	assert.Nil(t, exportDefinitions(&output, definitions, "kv"))
+	assert.Equal(t, "andare=to go\na, b=c\n", output.String())
+
+	// It would be read back as different sides
+	output.Reset()
+	assert.EqualError(t, exportDefinitions(&output, []Definition{defToGo, {From: "1+1", To: "=2"}}, "kv"),
+		"'1+1' can't be exported as kv, it contains '='")
+	assert.Empty(t, output.String())
+
+	output.Reset()
+	assert.Nil(t, exportDefinitions(&output, definitions, "json"))
+	assert.Contains(t, output.String(), `"from": "a, b"`)
+
+	assert.EqualError(t, exportDefinitions(&output, definitions, "xml"), "unknown format 'xml'")
+}
+
+func TestValidateCommand(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	valid := filepath.Join(dir, "valid.deck")
+	broken := filepath.Join(dir, "broken.deck")
+	ioutil.WriteFile(valid, []byte("[(andare) (to go)]"), 0644)
+	ioutil.WriteFile(broken, []byte("[(andare) (to go)]\n[(andare) ()]"), 0644)
+
+	assert.Nil(t, validateCommand([]string{"-deck-path", valid}))
+	assert.EqualError(t, validateCommand([]string{dir}), "2 problems found")
+}
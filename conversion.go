@@ -8,6 +8,31 @@ import (
 	"strings"
 )
 
+func convertCommand(args []string) error {
+	flags := newFlagSet("convert", "<file>...")
+	from := flags.String("from", "kv", "Format of the input files (kv: question=answer lines)")
+
+	flags.Parse(args)
+
+	if *from != "kv" {
+		return fmt.Errorf("unknown format '%s'", *from)
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no files to convert")
+	}
+
+	// Every file becomes <file>.deck
+	for _, path := range flags.Args() {
+		if err := convertKeyValueToDeckFile(path); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+
+	return nil
+}
+
 func convertKeyValueToDeckFile(path string) error {
 	definitions, err := loadKeyValueFile(path)
 
@@ -32,17 +57,10 @@ func createDeckFile(inputPath string, definitions []Definition) error {
 	f, err := os.Create(newPath)
 	defer f.Close()
 
-	format := `[
-    (%s)
-    (%s)
-]`
-
 	entries := []string{}
 
 	for _, def := range definitions {
-		entry := fmt.Sprintf(format, def.From, def.To)
-
-		entries = append(entries, entry)
+		entries = append(entries, formatDeckEntry(def))
 	}
 
 	_, err = f.WriteString((strings.Join(entries, "\n")))
@@ -50,6 +68,22 @@ func createDeckFile(inputPath string, definitions []Definition) error {
 	return err
 }
 
+// A card in the deck file format, with its ID if it has one.
+// Without an answer, it's a cloze sentence in a single block.
+func formatDeckEntry(def Definition) string {
+	blocks := fmt.Sprintf("    (%s)\n", escapeDeckText(def.From))
+
+	if def.To != "" {
+		blocks += fmt.Sprintf("    (%s)\n", escapeDeckText(def.To))
+	}
+
+	if def.ID != "" {
+		return fmt.Sprintf("[ @id %s\n%s]", def.ID, blocks)
+	}
+
+	return fmt.Sprintf("[\n%s]", blocks)
+}
+
 func loadKeyValueFile(path string) ([]Definition, error) {
 	file, err := os.Open(path)
 	defer file.Close()
@@ -1,20 +1,192 @@
 package main
 
 import (
-	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"os"
+	"strconv"
 	"strings"
 )
 
 type Definition struct {
+	// Stable identity of a card, it survives edits of the answer.
+	// Either set explicitly with "@id" in the deck file or derived from the question.
+	ID   string `json:"id"`
 	From string `json:"from"`
 	To   string `json:"to"`
+
+	// Made from a gap of a cloze sentence, it's always asked with the gap blanked
+	Cloze bool `json:"cloze,omitempty"`
+
+	// Set for the two halves of a bidirectional card, each is asked in its own direction only.
+	// Empty for cards asked in the order given on the command line.
+	Direction string `json:"direction,omitempty"`
+
+	// Left out of study, set with "@suspended" on the card in the deck file
+	Suspended bool `json:"suspended,omitempty"`
+}
+
+// Suffix of the ID of the reversed half of a bidirectional card.
+const reversedIDSuffix = "-reversed"
+
+const defaultBoxCount = 3
+
+// Separates alternative answers within a block, e.g. "(to realize | to notice)". Escaped with a backslash
+// it's a literal pipe.
+const alternativesSeparator = '|'
+
+// Answers accepted for one side of a definition.
+func getAlternatives(side string) []string {
+	alternatives := []string{}
+
+	for _, alternative := range splitAlternatives(side) {
+		alternative = strings.TrimSpace(alternative)
+
+		if alternative != "" {
+			alternatives = append(alternatives, alternative)
+		}
+	}
+
+	return alternatives
+}
+
+// Split a side on separators that aren't escaped, escaped ones become literal pipes.
+func splitAlternatives(side string) []string {
+	alternatives := []string{}
+	runes := []rune(side)
+
+	var alternative strings.Builder
+
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == alternativesSeparator:
+			alternative.WriteRune(alternativesSeparator)
+			i++
+		case runes[i] == alternativesSeparator:
+			alternatives = append(alternatives, alternative.String())
+			alternative.Reset()
+		default:
+			alternative.WriteRune(runes[i])
+		}
+	}
+
+	return append(alternatives, alternative.String())
+}
+
+// An alternative as it's written in a side with others, its pipes escaped.
+func escapeAlternative(alternative string) string {
+	return strings.Replace(alternative, string(alternativesSeparator), "\\"+string(alternativesSeparator), -1)
+}
+
+// The first answer accepted for a side, the side itself if it has no alternatives at all.
+func firstAlternative(side string) string {
+	alternatives := getAlternatives(side)
+
+	if len(alternatives) == 0 {
+		return strings.TrimSpace(side)
+	}
+
+	return alternatives[0]
+}
+
+// Show alternatives of a side as they're meant to be read, e.g. "to realize / to notice".
+func formatAlternatives(side string) string {
+	return strings.Join(getAlternatives(side), " / ")
+}
+
+// Settings from the deck file header, e.g. "@boxes 5".
+// Zero values mean the setting is not present in the header.
+type DeckOptions struct {
+	BoxCount  int
+	Algorithm string
+	MaxTypos  int
+	Accents   string
+
+	// Every card is bidirectional
+	Bidirectional bool
 }
 
 type Deck struct {
 	Definitions []Definition `json:"-"`
+	Options     DeckOptions  `json:"-"`
+	Algorithm   string       `json:"algorithm,omitempty"`
 	Leitner     *Leitner     `json:"leitner"`
+	SM2         *SM2         `json:"sm2,omitempty"`
+	FSRS        *FSRS        `json:"fsrs,omitempty"`
+}
+
+// Scheduler selected for the deck, Leitner unless another algorithm was chosen.
+func (deck *Deck) scheduler() Scheduler {
+	switch deck.Algorithm {
+	case algorithmSM2:
+		return deck.SM2
+	case algorithmFSRS:
+		return deck.FSRS
+	}
+
+	return deck.Leitner
+}
+
+func (deck *Deck) matcher() Matcher {
+	return Matcher{
+		MaxTypos:      deck.Options.MaxTypos,
+		StrictAccents: deck.Options.Accents == accentsStrict,
+	}
+}
+
+func (deck *Deck) useAlgorithm(algorithm string) {
+	deck.Algorithm = algorithm
+
+	if algorithm == algorithmSM2 && deck.SM2 == nil {
+		deck.SM2 = initSM2(deck.Definitions)
+	}
+
+	if algorithm == algorithmFSRS && deck.FSRS == nil {
+		deck.FSRS = initFSRS(deck.Definitions)
+	}
+}
+
+// Copy of the deck with its own copy of the schedule being used, to go back to when an answer is undone.
+func (deck *Deck) snapshot() *Deck {
+	snapshot := *deck
+
+	switch deck.Algorithm {
+	case algorithmSM2:
+		snapshot.SM2 = deck.SM2.clone()
+	case algorithmFSRS:
+		snapshot.FSRS = deck.FSRS.clone()
+	default:
+		snapshot.Leitner = deck.Leitner.clone()
+	}
+
+	return &snapshot
+}
+
+// Take in cards and options of the deck file edited while studying. The definition being asked is put back first.
+// Returns the changes to the schedule being used.
+func (deck *Deck) reload(edited *Deck) *Reconciliation {
+	deck.scheduler().skip()
+	deck.Definitions = edited.Definitions
+	deck.Options = edited.Options
+
+	reconciliation := deck.Leitner.reconcile(deck.Definitions)
+
+	if deck.SM2 != nil {
+		if sm2Reconciliation := deck.SM2.reconcile(deck.Definitions); deck.Algorithm == algorithmSM2 {
+			reconciliation = sm2Reconciliation
+		}
+	}
+
+	if deck.FSRS != nil {
+		if fsrsReconciliation := deck.FSRS.reconcile(deck.Definitions); deck.Algorithm == algorithmFSRS {
+			reconciliation = fsrsReconciliation
+		}
+	}
+
+	return reconciliation
 }
 
 func (deck *Deck) getRandomDefinition() *Definition {
@@ -27,51 +199,268 @@ func (deck *Deck) shuffle() {
 	})
 }
 
+// Comments are left in, the parser skips them and counts their lines.
 func loadFile(path string) (string, error) {
-	file, err := os.Open(path)
-	defer file.Close()
+	data, err := ioutil.ReadFile(path)
 
-	if err != nil {
-		return "", err
+	return string(data), err
+}
+
+func parseDeckOptions(directives map[string]string) (DeckOptions, error) {
+	options := DeckOptions{}
+
+	if value, ok := directives["boxes"]; ok {
+		boxCount, err := strconv.Atoi(value)
+
+		if err != nil || boxCount < 1 {
+			return options, fmt.Errorf("invalid number of boxes '%s'", value)
+		}
+
+		options.BoxCount = boxCount
 	}
 
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
+	if value, ok := directives["algorithm"]; ok {
+		if !isValidAlgorithm(value) {
+			return options, fmt.Errorf("unknown algorithm '%s'", value)
+		}
 
-	var lines []string
+		options.Algorithm = value
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
+	if value, ok := directives["typos"]; ok {
+		maxTypos, err := strconv.Atoi(value)
 
-		// Ignore empty lines or comments
-		if len(line) == 0 || line[0] == '#' {
-			continue
+		if err != nil || maxTypos < 0 {
+			return options, fmt.Errorf("invalid number of typos '%s'", value)
+		}
+
+		options.MaxTypos = maxTypos
+	}
+
+	if value, ok := directives["bidirectional"]; ok {
+		if value != "" {
+			return options, fmt.Errorf("@bidirectional takes no value, found '%s'", value)
+		}
+
+		options.Bidirectional = true
+	}
+
+	if value, ok := directives["accents"]; ok {
+		if !isValidAccentsMode(value) {
+			return options, fmt.Errorf("unknown accents mode '%s'", value)
 		}
 
-		lines = append(lines, line)
+		options.Accents = value
 	}
 
-	return strings.Join(lines, "\n"), err
+	return options, nil
 }
 
-func loadDeck(data string) *Deck {
-	groups := getStringsBetween(data, '[', ']')
+// Fails with the first *ParseError in the deck.
+func loadDeck(data string) (*Deck, error) {
+	parsed, errs := parseDeck(data)
 
-	var definitions []Definition
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
 
-	for _, group := range groups {
-		words := getStringsBetween(group, '(', ')')
+	options, err := parsed.options()
 
-		definition := Definition{
-			From: words[0],
-			To:   words[1],
-		}
+	if err != nil {
+		return nil, err
+	}
+
+	boxCount := defaultBoxCount
 
-		definitions = append(definitions, definition)
+	if options.BoxCount > 0 {
+		boxCount = options.BoxCount
 	}
 
+	definitions, _ := buildDefinitions(parsed, options)
+
 	return &Deck{
 		Definitions: definitions,
-		Leitner:     initLeitner(3, definitions),
+		Options:     options,
+		Leitner:     initLeitner(boxCount, definitions),
+	}, nil
+}
+
+// Definitions made from the cards of a deck file, and the index of the card each of them comes from.
+func buildDefinitions(parsed *parsedDeck, options DeckOptions) ([]Definition, []int) {
+	var definitions []Definition
+	var cards []int
+
+	for i, card := range parsed.Cards {
+		directives := parseDirectives(card.Directives)
+		_, suspended := directives["suspended"]
+
+		var cardDefinitions []Definition
+
+		if len(card.Blocks) == 1 {
+			cardDefinitions = expandCloze(card.Blocks[0].Text, directives["id"])
+		} else {
+			definition := Definition{
+				ID:   directives["id"],
+				From: card.Blocks[0].Text,
+				To:   card.Blocks[1].Text,
+			}
+
+			if _, ok := directives["bidirectional"]; ok || options.Bidirectional {
+				definition.Direction = directionForward
+			}
+
+			cardDefinitions = []Definition{definition}
+		}
+
+		for _, definition := range cardDefinitions {
+			definition.Suspended = suspended
+			definitions = append(definitions, definition)
+			cards = append(cards, i)
+		}
+	}
+
+	assignIDs(definitions)
+
+	return addReversedDefinitions(definitions, cards)
+}
+
+// The card of the deck file a definition comes from, nil if there's none with the ID
+// or the file can't be loaded.
+func findCard(data string, id string) *parsedCard {
+	parsed, errs := parseDeck(data)
+
+	if len(errs) > 0 {
+		return nil
+	}
+
+	options, err := parsed.options()
+
+	if err != nil {
+		return nil
+	}
+
+	definitions, cards := buildDefinitions(parsed, options)
+
+	for i, def := range definitions {
+		if def.ID == id {
+			return &parsed.Cards[cards[i]]
+		}
+	}
+
+	return nil
+}
+
+// Add "@suspended" to the card in the deck file, it's left out of study until the directive is removed.
+func suspendCard(path string, id string) error {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := loadFile(path)
+
+	if err != nil {
+		return err
+	}
+
+	card := findCard(data, id)
+
+	if card == nil {
+		return fmt.Errorf("card '%s' is not in the deck file", id)
+	}
+
+	if _, ok := parseDirectives(card.Directives)["suspended"]; ok {
+		return nil
+	}
+
+	offset := positionOffset(data, card.End)
+
+	return writeFileAtomic(path, []byte(data[:offset]+" @suspended"+data[offset:]), info.Mode().Perm())
+}
+
+// Bidirectional cards get a second definition right after them, asking the answer for the question.
+// The forward one keeps the card's ID, so progress made before the card became bidirectional stays with it.
+func addReversedDefinitions(definitions []Definition, cards []int) ([]Definition, []int) {
+	all := []Definition{}
+	allCards := []int{}
+
+	for i, def := range definitions {
+		all = append(all, def)
+		allCards = append(allCards, cards[i])
+
+		if def.Direction == directionForward {
+			all = append(all, Definition{
+				ID:        def.ID + reversedIDSuffix,
+				From:      def.To,
+				To:        def.From,
+				Direction: directionReversed,
+				Suspended: def.Suspended,
+			})
+			allCards = append(allCards, cards[i])
+		}
+	}
+
+	return all, allCards
+}
+
+// Parse directives such as "@id verb-12" into a map of names and values.
+// Directives without a value (e.g. "@reversed") map to an empty string.
+func parseDirectives(data string) map[string]string {
+	directives := make(map[string]string)
+
+	name := ""
+	var value []string
+
+	for _, field := range strings.Fields(data) {
+		if strings.HasPrefix(field, "@") {
+			if name != "" {
+				directives[name] = strings.Join(value, " ")
+			}
+
+			name = strings.TrimPrefix(field, "@")
+			value = nil
+
+			continue
+		}
+
+		value = append(value, field)
+	}
+
+	if name != "" {
+		directives[name] = strings.Join(value, " ")
+	}
+
+	return directives
+}
+
+// Derive an identifier from the question side only, so fixing a typo in the answer keeps the card's progress.
+func contentID(text string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(text), " "))
+	sum := sha1.Sum([]byte(normalized))
+
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// Give every definition without an explicit "@id" an identifier derived from its content.
+// Definitions sharing the same question are told apart by their order in the deck file.
+func assignIDs(definitions []Definition) {
+	used := make(map[string]int)
+
+	for i := range definitions {
+		def := &definitions[i]
+
+		if def.ID != "" {
+			continue
+		}
+
+		id := contentID(def.From)
+		used[id]++
+
+		if used[id] > 1 {
+			id = fmt.Sprintf("%s-%d", id, used[id])
+		}
+
+		def.ID = id
 	}
 }
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"io/ioutil"
 	"math/rand"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -9,18 +11,18 @@ import (
 
 func getTestDeck() *Deck {
 	return &Deck{
-		definitions: []Definition{
+		Definitions: []Definition{
 			Definition{
-				from: "bugs",
-				to:   "bunny",
+				From: "bugs",
+				To:   "bunny",
 			},
 			Definition{
-				from: "donald",
-				to:   "duck",
+				From: "donald",
+				To:   "duck",
 			},
 			Definition{
-				from: "red",
-				to:   "sox",
+				From: "red",
+				To:   "sox",
 			},
 		},
 	}
@@ -41,23 +43,78 @@ func TestLoadDeck_simple_case(t *testing.T) {
         ]
     `
 
-	deck := loadDeck(data)
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
 
 	expected := []Definition{
 		Definition{
-			from: "red",
-			to:   "sox",
+			ID:   contentID("red"),
+			From: "red",
+			To:   "sox",
 		},
 		Definition{
-			from: "bugs",
-			to:   "bunny",
+			ID:   contentID("bugs"),
+			From: "bugs",
+			To:   "bunny",
 		},
 	}
-	actual := deck.definitions
+	actual := deck.Definitions
 
 	assert.Equal(t, expected, actual)
 }
 
+func TestLoadDeck_explicit_id(t *testing.T) {
+	data := `
+        [ @id colour-1
+            (red)
+            (sox)
+        ]
+    `
+
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
+
+	assert.Equal(t, []Definition{{ID: "colour-1", From: "red", To: "sox"}}, deck.Definitions)
+}
+
+func TestLoadDeck_duplicate_questions(t *testing.T) {
+	data := `
+        [(red) (sox)]
+        [(red) (wings)]
+    `
+
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
+
+	assert.Equal(t, contentID("red"), deck.Definitions[0].ID)
+	assert.Equal(t, contentID("red")+"-2", deck.Definitions[1].ID)
+}
+
+func TestLoadDeck_missing_answer(t *testing.T) {
+	data := `
+        [(red) (sox)]
+        [(blue)]
+    `
+
+	_, err := loadDeck(data)
+
+	assert.EqualError(t, err, "line 3, column 9: a card needs a question and an answer, or a sentence with gaps such as {{c1::text}}")
+}
+
+func TestContentID_ignores_case_and_whitespace(t *testing.T) {
+	assert.Equal(t, contentID("to go"), contentID("  To   go "))
+	assert.NotEqual(t, contentID("to go"), contentID("to be"))
+}
+
+func TestParseDirectives(t *testing.T) {
+	directives := parseDirectives("@id verb 12 @reversed")
+
+	assert.Equal(t, map[string]string{"id": "verb 12", "reversed": ""}, directives)
+}
+
 func TestShuffleDeck(t *testing.T) {
 	rand.Seed(10)
 	deck := getTestDeck()
@@ -66,19 +123,19 @@ func TestShuffleDeck(t *testing.T) {
 
 	expected := []Definition{
 		Definition{
-			from: "red",
-			to:   "sox",
+			From: "red",
+			To:   "sox",
 		},
 		Definition{
-			from: "bugs",
-			to:   "bunny",
+			From: "bugs",
+			To:   "bunny",
 		},
 		Definition{
-			from: "donald",
-			to:   "duck",
+			From: "donald",
+			To:   "duck",
 		},
 	}
-	actual := deck.definitions
+	actual := deck.Definitions
 
 	assert.Equal(t, expected, actual)
 }
@@ -87,8 +144,203 @@ func TestGetRandomDefinition(t *testing.T) {
 	rand.Seed(16)
 	deck := getTestDeck()
 
-	expected := &Definition{from: "bugs", to: "bunny"}
+	expected := &Definition{From: "bugs", To: "bunny"}
 	actual := deck.getRandomDefinition()
 
 	assert.Equal(t, expected, actual)
 }
+
+func TestLoadDeck_box_count_header(t *testing.T) {
+	data := `
+        @boxes 5
+
+        [(red) (sox)]
+    `
+
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 5, deck.Options.BoxCount)
+	assert.Equal(t, 5, deck.Leitner.BoxCount)
+	assert.Equal(t, 5, len(deck.Leitner.Boxes))
+}
+
+func TestLoadDeck_invalid_box_count_header(t *testing.T) {
+	_, err := loadDeck("@boxes many [(red) (sox)]")
+
+	assert.NotNil(t, err)
+}
+
+func TestDeckScheduler(t *testing.T) {
+	deck, _ := loadDeck("[(red) (sox)]")
+
+	assert.Equal(t, deck.Leitner, deck.scheduler())
+
+	deck.useAlgorithm(algorithmSM2)
+
+	assert.Equal(t, 1, len(deck.SM2.Cards))
+	assert.Equal(t, deck.SM2, deck.scheduler())
+}
+
+func TestLoadDeck_algorithm_header(t *testing.T) {
+	deck, err := loadDeck("@algorithm sm2 [(red) (sox)]")
+
+	assert.Nil(t, err)
+	assert.Equal(t, algorithmSM2, deck.Options.Algorithm)
+
+	_, err = loadDeck("@algorithm unknown [(red) (sox)]")
+
+	assert.NotNil(t, err)
+}
+
+func TestLoadDeck_typos_header(t *testing.T) {
+	deck, err := loadDeck("@typos 2 [(red) (sox)]")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Matcher{MaxTypos: 2}, deck.matcher())
+
+	_, err = loadDeck("@typos -1 [(red) (sox)]")
+
+	assert.NotNil(t, err)
+}
+
+func TestLoadDeck_accents_header(t *testing.T) {
+	deck, err := loadDeck("@accents strict [(red) (sox)]")
+
+	assert.Nil(t, err)
+	assert.True(t, deck.matcher().StrictAccents)
+
+	_, err = loadDeck("@accents loose [(red) (sox)]")
+
+	assert.NotNil(t, err)
+}
+
+func TestGetAlternatives(t *testing.T) {
+	assert.Equal(t, []string{"to realize", "to notice"}, getAlternatives("to realize | to notice"))
+	assert.Equal(t, []string{"to go"}, getAlternatives("to go"))
+	assert.Equal(t, []string{"to go"}, getAlternatives("to go |"))
+
+	// Escaped pipes are literal
+	assert.Equal(t, []string{"a | b", "or"}, getAlternatives(`a \| b | or`))
+	assert.Equal(t, []string{"|"}, getAlternatives(`\|`))
+}
+
+func TestFormatAlternatives(t *testing.T) {
+	assert.Equal(t, "to realize / to notice", formatAlternatives("to realize|to notice"))
+	assert.Equal(t, "a | b", formatAlternatives(`a \| b`))
+}
+
+func TestLoadDeck_escaped_pipe(t *testing.T) {
+	deck, err := loadDeck(`[(pipe) (\| | vertical bar)]`)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"|", "vertical bar"}, getAlternatives(deck.Definitions[0].To))
+
+	match, _ := describeAnswer("|", deck.Definitions[0].To, Matcher{})
+	assert.Equal(t, matchExact, match)
+}
+
+func TestLoadDeck_bidirectional_card(t *testing.T) {
+	data := `
+        [ @bidirectional (andare) (to go) ]
+        [ (essere) (to be) ]
+    `
+
+	deck, err := loadDeck(data)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Definition{
+		{ID: contentID("andare"), From: "andare", To: "to go", Direction: directionForward},
+		{ID: contentID("andare") + "-reversed", From: "to go", To: "andare", Direction: directionReversed},
+		{ID: contentID("essere"), From: "essere", To: "to be"},
+	}, deck.Definitions)
+}
+
+func TestLoadDeck_bidirectional_header(t *testing.T) {
+	deck, err := loadDeck("@bidirectional [ @id go (andare) (to go) ] [ (essere) (to be) ]")
+
+	assert.Nil(t, err)
+	assert.True(t, deck.Options.Bidirectional)
+	assert.Len(t, deck.Definitions, 4)
+	assert.Equal(t, "go-reversed", deck.Definitions[1].ID)
+
+	_, err = loadDeck("@bidirectional yes [(andare) (to go)]")
+
+	assert.NotNil(t, err)
+}
+
+func TestLoadDeck_suspended_card(t *testing.T) {
+	deck, err := loadDeck("[(andare) (to go) @suspended @bidirectional] [(essere) (to be)] [(Io {{c1::vado}}) @suspended]")
+
+	assert.Nil(t, err)
+	assert.True(t, deck.Definitions[0].Suspended)
+	assert.True(t, deck.Definitions[1].Suspended)
+	assert.False(t, deck.Definitions[2].Suspended)
+	assert.True(t, deck.Definitions[3].Suspended)
+}
+
+func TestFindCard(t *testing.T) {
+	data := "[(andare) (to go)]\n\n[@bidirectional\n  (essere)\n  (to be)\n]"
+
+	card := findCard(data, contentID("essere")+reversedIDSuffix)
+
+	assert.NotNil(t, card)
+	assert.Equal(t, Position{3, 1}, card.Position)
+	assert.Nil(t, findCard(data, "vedere"))
+	assert.Nil(t, findCard("[(andare)", contentID("andare")))
+}
+
+func TestSuspendCard(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.txt")
+	ioutil.WriteFile(path, []byte("[(andare) (to go)]\n[ (essere)\n  (to be \\] ) ]\n"), 0644)
+
+	assert.Nil(t, suspendCard(path, contentID("essere")))
+	assert.NotNil(t, suspendCard(path, "vedere"))
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "[(andare) (to go)]\n[ (essere)\n  (to be \\] )  @suspended]\n", string(data))
+
+	// Already suspended
+	assert.Nil(t, suspendCard(path, contentID("essere")))
+
+	deck, err := readDeckFile(path)
+
+	assert.Nil(t, err)
+	assert.True(t, deck.Definitions[1].Suspended)
+}
+
+func TestDeckSnapshot(t *testing.T) {
+	deck, _ := loadDeck("[@id andare (andare) (to go)] [@id essere (essere) (to be)]")
+	deck.useAlgorithm(algorithmSM2)
+
+	assert.Equal(t, "andare", deck.scheduler().next().ID)
+
+	snapshot := deck.snapshot()
+	deck.scheduler().record(gradeGood)
+
+	assert.Equal(t, deck.Leitner, snapshot.Leitner)
+	assert.Equal(t, 1, deck.scheduler().level("andare"))
+	assert.Equal(t, 0, snapshot.scheduler().level("andare"))
+	assert.Equal(t, "andare", snapshot.SM2.CurrentCard.Definition.ID)
+}
+
+func TestDeckReload(t *testing.T) {
+	deck, _ := loadDeck("[@id andare (andare) (to go)] [@id essere (essere) (to be)]")
+	deck.useAlgorithm(algorithmSM2)
+
+	assert.Equal(t, "andare", deck.scheduler().next().ID)
+
+	edited, _ := loadDeck("[@id andare (andare) (to go) @suspended] [@id essere (essere) (to be)] [@id vedere (vedere) (to see)]")
+	reconciliation := deck.reload(edited)
+
+	assert.Len(t, reconciliation.Added, 1)
+	assert.Len(t, reconciliation.Updated, 1)
+	assert.Len(t, deck.Definitions, 3)
+	assert.Len(t, deck.Leitner.Boxes[0].Definitions, 3)
+
+	// The definition being asked is put back, and it's suspended now
+	assert.Equal(t, "essere", deck.scheduler().next().ID)
+}
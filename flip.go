@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/logrusorgru/aurora"
+)
+
+// Marking an answer correct (y) or wrong (n), or grading it directly by the number or first letter of a grade.
+func gradeForKey(key rune) (Grade, bool) {
+	switch key {
+	case 'y', 'Y':
+		return gradeGood, true
+	case 'n', 'N':
+		return gradeAgain, true
+	}
+
+	return parseGrade(strings.ToLower(string(key)))
+}
+
+// Show the answer once Enter is pressed and let the answer be graded with a single key, nothing is typed.
+// Returns false if the input is closed or the session is interrupted.
+func flipCard(input *bufio.Scanner, question *Question) (Grade, bool) {
+	fmt.Printf("%s\n", aurora.Yellow("Press Enter to show the answer"))
+
+	for {
+		key, ok := readKey(input)
+
+		if !ok {
+			return 0, false
+		}
+
+		if key == keyEnter || key == '\n' || key == ' ' {
+			break
+		}
+	}
+
+	fmt.Printf("\n%s:\n%s\n\n", aurora.Blue("Answer"), renderMarkup(formatAlternatives(question.Answer)))
+	fmt.Printf("%s: [y] correct [n] wrong, or [1] again [2] hard [3] good [4] easy\n", aurora.Yellow("Grade"))
+
+	for {
+		key, ok := readKey(input)
+
+		if !ok {
+			return 0, false
+		}
+
+		if grade, ok := gradeForKey(key); ok {
+			fmt.Println()
+
+			return grade, true
+		}
+	}
+}
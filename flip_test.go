@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGradeForKey(t *testing.T) {
+	for key, expected := range map[rune]Grade{
+		'y': gradeGood,
+		'N': gradeAgain,
+		'1': gradeAgain,
+		'2': gradeHard,
+		'h': gradeHard,
+		'4': gradeEasy,
+		'E': gradeEasy,
+	} {
+		grade, ok := gradeForKey(key)
+
+		assert.True(t, ok)
+		assert.Equal(t, expected, grade, string(key))
+	}
+
+	_, ok := gradeForKey('x')
+	assert.False(t, ok)
+
+	_, ok = gradeForKey('5')
+	assert.False(t, ok)
+}
+
+func TestReadKeyLine(t *testing.T) {
+	input := bufio.NewScanner(strings.NewReader("\n  yes\n"))
+
+	key, ok := readKeyLine(input)
+	assert.True(t, ok)
+	assert.Equal(t, keyEnter, key)
+
+	key, ok = readKeyLine(input)
+	assert.True(t, ok)
+	assert.Equal(t, 'y', key)
+
+	_, ok = readKeyLine(input)
+	assert.False(t, ok)
+}
+
+func TestFlipCard(t *testing.T) {
+	question := &Question{Text: "andare", Answer: "to go"}
+
+	// Keys other than Enter don't show the answer, unknown keys don't grade it
+	grade, ok := flipCard(bufio.NewScanner(strings.NewReader("x\n\nz\n2\n")), question)
+
+	assert.True(t, ok)
+	assert.Equal(t, gradeHard, grade)
+
+	grade, ok = flipCard(bufio.NewScanner(strings.NewReader("\nn\n")), question)
+
+	assert.True(t, ok)
+	assert.Equal(t, gradeAgain, grade)
+}
+
+func TestFlipCard_input_closed(t *testing.T) {
+	_, ok := flipCard(bufio.NewScanner(strings.NewReader("\n")), &Question{Answer: "to go"})
+
+	assert.False(t, ok)
+}
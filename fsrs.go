@@ -0,0 +1,199 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	fsrsDecay            = -0.5
+	fsrsFactor           = 19.0 / 81.0
+	fsrsDesiredRetention = 0.9
+)
+
+// Default FSRS-4.5 weights, used until they're fitted to the deck's own reviews.
+var fsrsDefaultWeights = []float64{
+	0.4872, 1.4003, 3.7145, 13.8206, 5.1618, 1.2298, 0.8975, 0.031, 1.6474,
+	0.1367, 1.0461, 2.1072, 0.0793, 0.3246, 1.587, 0.2272, 2.8755,
+}
+
+type FSRSCard struct {
+	ScheduledCard
+
+	Stability  float64 `json:"stability"`
+	Difficulty float64 `json:"difficulty"`
+}
+
+func newFSRSCard(card ScheduledCard) *FSRSCard {
+	return &FSRSCard{ScheduledCard: card}
+}
+
+func (card *FSRSCard) scheduled() *ScheduledCard {
+	return &card.ScheduledCard
+}
+
+func (card *FSRSCard) copy() *FSRSCard {
+	clone := *card
+	clone.Reviews = append([]Review{}, card.Reviews...)
+
+	return &clone
+}
+
+// Free Spaced Repetition Scheduler: models memory of every card with its stability (days until
+// the chance of recall drops to 90%) and difficulty, and asks it again when it's about to be forgotten.
+type FSRS struct {
+	dueQueue[*FSRSCard]
+
+	// Loaded from the parameters file written by the optimize command
+	Weights []float64 `json:"-"`
+}
+
+func initFSRS(definitions []Definition) *FSRS {
+	fsrs := &FSRS{}
+
+	fsrs.reconcile(definitions)
+
+	return fsrs
+}
+
+func (card *FSRSCard) isNew() bool {
+	return len(card.scheduledReviews()) == 0
+}
+
+// Time of the last review that changed the schedule, the card mustn't be new.
+func (card *FSRSCard) lastReview() time.Time {
+	reviews := card.scheduledReviews()
+
+	return reviews[len(reviews)-1].Time
+}
+
+// Probability of recalling a card t days after the last review.
+func fsrsRetrievability(t float64, stability float64) float64 {
+	return math.Pow(1+fsrsFactor*t/stability, fsrsDecay)
+}
+
+// Days after which retrievability drops to the desired retention.
+func fsrsInterval(stability float64) int {
+	interval := stability / fsrsFactor * (math.Pow(fsrsDesiredRetention, 1/fsrsDecay) - 1)
+
+	return int(math.Max(1, math.Round(interval)))
+}
+
+func fsrsInitialStability(w []float64, rating Grade) float64 {
+	return math.Max(w[rating-1], 0.1)
+}
+
+func fsrsInitialDifficulty(w []float64, rating Grade) float64 {
+	return clamp(w[4]-float64(rating-3)*w[5], 1, 10)
+}
+
+func fsrsNextDifficulty(w []float64, difficulty float64, rating Grade) float64 {
+	next := difficulty - w[6]*float64(rating-3)
+
+	// Mean reversion towards the difficulty of a new card answered with "good"
+	return clamp(w[7]*fsrsInitialDifficulty(w, gradeGood)+(1-w[7])*next, 1, 10)
+}
+
+func fsrsNextStability(w []float64, difficulty float64, stability float64, retrievability float64, rating Grade) float64 {
+	if rating == gradeAgain {
+		return w[11] * math.Pow(difficulty, -w[12]) * (math.Pow(stability+1, w[13]) - 1) * math.Exp(w[14]*(1-retrievability))
+	}
+
+	hardPenalty := 1.0
+	easyBonus := 1.0
+
+	if rating == gradeHard {
+		hardPenalty = w[15]
+	}
+
+	if rating == gradeEasy {
+		easyBonus = w[16]
+	}
+
+	return stability * (1 + math.Exp(w[8])*(11-difficulty)*math.Pow(stability, -w[9])*
+		(math.Exp(w[10]*(1-retrievability))-1)*hardPenalty*easyBonus)
+}
+
+// Stability and difficulty after a review given the state before it.
+// Elapsed is the number of days since the previous review, ignored for new cards.
+func fsrsStep(w []float64, isNew bool, stability float64, difficulty float64, elapsed float64, rating Grade) (float64, float64) {
+	if isNew {
+		return fsrsInitialStability(w, rating), fsrsInitialDifficulty(w, rating)
+	}
+
+	retrievability := fsrsRetrievability(elapsed, stability)
+
+	return fsrsNextStability(w, difficulty, stability, retrievability, rating), fsrsNextDifficulty(w, difficulty, rating)
+}
+
+func clamp(value float64, min float64, max float64) float64 {
+	return math.Max(min, math.Min(max, value))
+}
+
+func daysBetween(from time.Time, to time.Time) float64 {
+	return to.Sub(from).Hours() / 24
+}
+
+func (fsrs *FSRS) weights() []float64 {
+	if len(fsrs.Weights) == len(fsrsDefaultWeights) {
+		return fsrs.Weights
+	}
+
+	return fsrsDefaultWeights
+}
+
+// Copy sharing nothing that changes while studying, e.g. to undo an answer.
+func (fsrs *FSRS) clone() *FSRS {
+	clone := *fsrs
+	clone.dueQueue = fsrs.cloneQueue()
+
+	return &clone
+}
+
+// Grades are used as FSRS ratings directly, the model is built around the same four levels.
+func (fsrs *FSRS) record(rating Grade) {
+	card := fsrs.CurrentCard
+
+	if card == nil {
+		return
+	}
+
+	if fsrs.relearn(rating, rating > gradeAgain) {
+		return
+	}
+
+	currentTime := now()
+	elapsed := 0.0
+
+	if !card.isNew() {
+		elapsed = daysBetween(card.lastReview(), currentTime)
+	}
+
+	card.Stability, card.Difficulty = fsrsStep(fsrs.weights(), card.isNew(), card.Stability, card.Difficulty, elapsed, rating)
+	card.Reviews = append(card.Reviews, Review{
+		Time:  currentTime,
+		Grade: rating,
+	})
+	card.Due = startOfDay(currentTime).AddDate(0, 0, fsrsInterval(card.Stability))
+}
+
+func (fsrs *FSRS) level(id string) int {
+	card, ok := fsrs.Cards[id]
+
+	if !ok {
+		return -1
+	}
+
+	reviews := card.scheduledReviews()
+	streak := 0
+
+	for i := len(reviews) - 1; i >= 0 && reviews[i].Grade != gradeAgain; i-- {
+		streak++
+	}
+
+	return streak
+}
+
+func (fsrs *FSRS) reconcile(definitions []Definition) *Reconciliation {
+	return fsrs.reconcileCards(definitions, newFSRSCard)
+}
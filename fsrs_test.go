@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFSRSRetrievability(t *testing.T) {
+	assert.Equal(t, 1.0, fsrsRetrievability(0, 5))
+	assert.InDelta(t, 0.9, fsrsRetrievability(5, 5), 0.0001)
+	assert.True(t, fsrsRetrievability(10, 5) < 0.9)
+}
+
+func TestFSRSInterval(t *testing.T) {
+	// With 90% desired retention the interval is the stability
+	assert.Equal(t, 5, fsrsInterval(5))
+	assert.Equal(t, 1, fsrsInterval(0.2))
+}
+
+func TestFSRS_first_review(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	fsrs := initFSRS([]Definition{defToGo})
+
+	assert.Equal(t, &defToGo, fsrs.next())
+	fsrs.record(gradeGood)
+
+	card := fsrs.Cards["andare"]
+
+	assert.Equal(t, fsrsDefaultWeights[2], card.Stability)
+	assert.Equal(t, fsrsDefaultWeights[4], card.Difficulty)
+	assert.Equal(t, []Review{{Time: day, Grade: gradeGood}}, card.Reviews)
+	assert.Equal(t, time.Date(2020, 5, 14, 0, 0, 0, 0, time.UTC), card.Due)
+	assert.Nil(t, fsrs.next())
+}
+
+func TestFSRS_stability_grows_with_correct_answers(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	restore := setNow(day)
+	defer restore()
+
+	fsrs := initFSRS([]Definition{defToGo})
+	card := fsrs.Cards["andare"]
+
+	fsrs.next()
+	fsrs.record(gradeGood)
+	first := card.Stability
+
+	setNow(card.Due)
+	assert.Equal(t, &defToGo, fsrs.next())
+	fsrs.record(gradeGood)
+
+	assert.True(t, card.Stability > first)
+	assert.Equal(t, 2, len(card.Reviews))
+}
+
+func TestFSRS_wrong_answer(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	restore := setNow(day)
+	defer restore()
+
+	fsrs := initFSRS([]Definition{defToGo})
+	card := fsrs.Cards["andare"]
+
+	fsrs.next()
+	fsrs.record(gradeGood)
+	stability := card.Stability
+
+	setNow(card.Due)
+	fsrs.next()
+	fsrs.record(gradeAgain)
+
+	assert.True(t, card.Stability < stability)
+
+	// Repeated in the same session until it's answered correctly
+	assert.Equal(t, &defToGo, fsrs.next())
+	fsrs.record(gradeGood)
+	assert.Nil(t, fsrs.next())
+
+	// The relearning step is recorded, but it doesn't count as a review
+	assert.Equal(t, 3, len(card.Reviews))
+	assert.True(t, card.Reviews[2].Relearning)
+	assert.Equal(t, 0, fsrs.level("andare"))
+}
+
+func TestFSRS_reconcile(t *testing.T) {
+	fsrs := initFSRS([]Definition{defToGo, defToBe})
+
+	reconciliation := fsrs.reconcile([]Definition{defToGo, defToSee})
+
+	assert.Equal(t, []Definition{defToSee}, reconciliation.Added)
+	assert.Equal(t, []Definition{defToBe}, reconciliation.Removed)
+	assert.Equal(t, 2, len(fsrs.Cards))
+}
+
+func TestFSRSLevel(t *testing.T) {
+	fsrs := initFSRS([]Definition{defToGo})
+	fsrs.Cards["andare"].Reviews = []Review{{Grade: gradeGood}, {Grade: gradeAgain}, {Grade: gradeHard}, {Grade: gradeEasy}}
+
+	assert.Equal(t, 2, fsrs.level("andare"))
+	assert.Equal(t, -1, fsrs.level("vedere"))
+}
+
+func TestFSRS_leaves_out_suspended_cards(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	suspended := Definition{ID: "andare", From: "andare", To: "to go", Suspended: true}
+	scheduler := initFSRS([]Definition{suspended, defToBe})
+
+	assert.Equal(t, &defToBe, scheduler.next())
+	scheduler.record(gradeGood)
+
+	assert.Nil(t, scheduler.next())
+	assert.Equal(t, scheduler.Cards["essere"].Due, scheduler.nextDue())
+}
+
+func TestFSRSSkip(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	scheduler := initFSRS([]Definition{defToGo, defToBe})
+
+	assert.Equal(t, &defToGo, scheduler.next())
+	scheduler.skip()
+
+	assert.Equal(t, &defToBe, scheduler.next())
+	scheduler.skip()
+
+	// Only skipped cards are left
+	assert.Equal(t, &defToGo, scheduler.next())
+}
+
+func TestFSRSClone(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	scheduler := initFSRS([]Definition{defToGo, defToBe})
+	scheduler.next()
+
+	clone := scheduler.clone()
+	scheduler.record(gradeAgain)
+
+	assert.Empty(t, clone.Cards["andare"].Reviews)
+	assert.Empty(t, clone.relearning)
+	assert.Equal(t, clone.Cards["andare"], clone.CurrentCard)
+	assert.Len(t, scheduler.Cards["andare"].Reviews, 1)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const (
+	defaultBackupCount = 5
+	backupTimeFormat   = "20060102-150405"
+)
+
+func historyPath(deckPath string) string {
+	return fmt.Sprintf("%s.history.json", deckPath)
+}
+
+// Load progress saved by a previous session.
+// Returns nil if the history file does not exist yet.
+func loadHistory(path string) (*Deck, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var history Deck
+
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+
+	if history.Leitner == nil || len(history.Leitner.Boxes) == 0 {
+		return nil, nil
+	}
+
+	return &history, nil
+}
+
+// Write the file so that it's either fully written or not changed at all:
+// data goes to a temporary file in the same directory, which replaces the file once it's on disk.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Make the rename itself durable
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+
+	return nil
+}
+
+// Backups of the history file, the most recent first.
+func listBackups(path string) ([]string, error) {
+	backups, err := filepath.Glob(path + ".*.bak")
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	return backups, nil
+}
+
+func backupTime(path string, backup string) (time.Time, error) {
+	suffix := backup[len(path)+1 : len(backup)-len(".bak")]
+
+	return time.ParseInLocation(backupTimeFormat, suffix, time.Local)
+}
+
+// Copy the history file to a timestamped backup and remove backups beyond the most recent ones to keep.
+// Keeping none turns backups off, existing ones are left alone.
+func backupHistory(path string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.%s.bak", path, now().Format(backupTimeFormat))
+
+	if err := writeFileAtomic(backup, data, 0644); err != nil {
+		return err
+	}
+
+	backups, err := listBackups(path)
+
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func countDefinitions(history *Deck) int {
+	count := 0
+
+	for _, box := range history.Leitner.Boxes {
+		count += len(box.Definitions)
+	}
+
+	return count
+}
+
+func printBackups(path string, backups []string) {
+	if len(backups) == 0 {
+		fmt.Printf("No backups of %s\n", path)
+		return
+	}
+
+	fmt.Printf("Backups of %s:\n", path)
+
+	for i, backup := range backups {
+		description := "unreadable"
+
+		if history, err := loadHistory(backup); err == nil && history != nil {
+			description = fmt.Sprintf("%d definitions", countDefinitions(history))
+		}
+
+		if created, err := backupTime(path, backup); err == nil {
+			fmt.Printf("\t%d\t%s\t%s\n", i+1, created.Format("2006-01-02 15:04:05"), description)
+		} else {
+			fmt.Printf("\t%d\t%s\t%s\n", i+1, backup, description)
+		}
+	}
+
+	fmt.Println("\nRestore one with -backup <number>")
+}
+
+// List backups of a deck's history file or roll the history back to one of them.
+// The current history is backed up first, so restoring can be undone.
+func restoreCommand(args []string) error {
+	flags := newFlagSet("restore", "-deck-path <deck> [-backup <n>]")
+	deckPath := flags.String("deck-path", "", "Path to deck file")
+	number := flags.Int("backup", 0, "Number of the backup to restore, as listed without this flag")
+	keep := flags.Int("backups", defaultBackupCount, "Number of history backups to keep")
+
+	flags.Parse(args)
+
+	if *keep < 0 {
+		return fmt.Errorf("invalid number of backups %d, it can't be negative", *keep)
+	}
+
+	path := historyPath(*deckPath)
+	backups, err := listBackups(path)
+
+	if err != nil {
+		return err
+	}
+
+	if *number == 0 {
+		printBackups(path, backups)
+		return nil
+	}
+
+	if *number < 1 || *number > len(backups) {
+		return fmt.Errorf("no backup %d, see the list of backups without -backup", *number)
+	}
+
+	backup := backups[*number-1]
+	history, err := loadHistory(backup)
+
+	if err != nil || history == nil {
+		return fmt.Errorf("backup %s is not a valid history file", backup)
+	}
+
+	data, err := ioutil.ReadFile(backup)
+
+	if err != nil {
+		return err
+	}
+
+	if err := backupHistory(path, *keep); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(path, data, 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s from %s\n", path, backup)
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getTempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "repetition")
+
+	assert.Nil(t, err)
+
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.history.json")
+
+	assert.Nil(t, writeFileAtomic(path, []byte("first"), 0644))
+	assert.Nil(t, writeFileAtomic(path, []byte("second"), 0644))
+
+	data, err := ioutil.ReadFile(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "second", string(data))
+
+	// No temporary files are left behind
+	files, _ := ioutil.ReadDir(dir)
+
+	assert.Equal(t, 1, len(files))
+}
+
+func TestLoadHistory_missing_file(t *testing.T) {
+	history, err := loadHistory("/does/not/exist.history.json")
+
+	assert.Nil(t, err)
+	assert.Nil(t, history)
+}
+
+func TestBackupHistory_keeps_most_recent_backups(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.history.json")
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.Local)
+	restore := setNow(day)
+	defer restore()
+
+	// Nothing to back up yet
+	assert.Nil(t, backupHistory(path, 2))
+
+	backups, _ := listBackups(path)
+
+	assert.Equal(t, 0, len(backups))
+
+	for i := 0; i < 3; i++ {
+		setNow(day.AddDate(0, 0, i))
+		ioutil.WriteFile(path, []byte{byte('a' + i)}, 0644)
+		assert.Nil(t, backupHistory(path, 2))
+	}
+
+	backups, _ = listBackups(path)
+
+	assert.Equal(t, []string{
+		path + ".20200512-153000.bak",
+		path + ".20200511-153000.bak",
+	}, backups)
+
+	created, err := backupTime(path, backups[0])
+
+	assert.Nil(t, err)
+	assert.Equal(t, day.AddDate(0, 0, 2), created)
+
+	data, _ := ioutil.ReadFile(backups[0])
+
+	assert.Equal(t, "c", string(data))
+}
+
+func TestBackupHistory_none_kept(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.history.json")
+	restore := setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.Local))
+	defer restore()
+
+	ioutil.WriteFile(path, []byte("a"), 0644)
+	assert.Nil(t, backupHistory(path, 1))
+
+	// Nothing is backed up, and the backup made before stays
+	setNow(time.Date(2020, 5, 11, 15, 30, 0, 0, time.Local))
+	assert.Nil(t, backupHistory(path, 0))
+
+	backups, _ := listBackups(path)
+
+	assert.Equal(t, []string{path + ".20200510-153000.bak"}, backups)
+}
+
+func TestBackups_negative(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	deckPath := filepath.Join(dir, "deck")
+	ioutil.WriteFile(deckPath, []byte("[(andare) (to go)]"), 0644)
+	ioutil.WriteFile(historyPath(deckPath), []byte("{}"), 0644)
+
+	message := "invalid number of backups -1, it can't be negative"
+
+	assert.EqualError(t, studyCommand([]string{"-tui=false", "-backups", "-1", "-deck-path", deckPath}), message)
+	assert.EqualError(t, restoreCommand([]string{"-backups", "-1", "-deck-path", deckPath, "-backup", "1"}), message)
+}
+
+func TestRestoreCommand(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	deckPath := filepath.Join(dir, "deck")
+	path := historyPath(deckPath)
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.Local)
+	restore := setNow(day)
+	defer restore()
+
+	old := `{"leitner": {"box_count": 1, "boxes": [{"box_number": 0, "definitions": []}]}}`
+
+	ioutil.WriteFile(path, []byte(old), 0644)
+	assert.Nil(t, backupHistory(path, 5))
+	ioutil.WriteFile(path, []byte("broken"), 0644)
+
+	setNow(day.AddDate(0, 0, 1))
+
+	assert.NotNil(t, restoreCommand([]string{"-deck-path", deckPath, "-backup", "2"}))
+	assert.Nil(t, restoreCommand([]string{"-deck-path", deckPath, "-backup", "1"}))
+
+	data, _ := ioutil.ReadFile(path)
+
+	assert.Equal(t, old, string(data))
+
+	// The overwritten history is backed up too
+	backups, _ := listBackups(path)
+
+	assert.Equal(t, 2, len(backups))
+}
@@ -1,9 +1,46 @@
 package main
 
 import (
+	"encoding/json"
+	"math"
 	"sort"
+	"time"
 )
 
+// Days between reviews of definitions in the consecutive boxes.
+// Boxes beyond the table double the interval of the previous one.
+var defaultIntervals = []int{1, 3, 7, 14, 30, 60, 120, 240}
+
+// How a grade moves a definition between boxes: Reset puts it back in the first box,
+// otherwise it moves by Step boxes, up for positive and down for negative steps.
+type BoxMove struct {
+	Reset bool `json:"reset,omitempty"`
+	Step  int  `json:"step,omitempty"`
+}
+
+func getDefaultMoves() map[Grade]BoxMove {
+	return map[Grade]BoxMove{
+		gradeAgain: {Reset: true},
+		gradeHard:  {Step: 0},
+		gradeGood:  {Step: 1},
+		gradeEasy:  {Step: 2},
+	}
+}
+
+func getDefaultIntervals(boxCount int) []int {
+	intervals := make([]int, boxCount)
+
+	for i := range intervals {
+		if i < len(defaultIntervals) {
+			intervals[i] = defaultIntervals[i]
+		} else {
+			intervals[i] = intervals[i-1] * 2
+		}
+	}
+
+	return intervals
+}
+
 type Box struct {
 	BoxNumber   int          `json:"box_number"`
 	Definitions []Definition `json:"definitions"`
@@ -19,42 +56,174 @@ type Leitner struct {
 	// n - 1st box, 2nd box, ..., n - 1 box, n box
 	Stage int `json:"stage"`
 
+	// Review interval of every box, in days
+	Intervals []int `json:"intervals"`
+
+	// Time of the last correct answer, keyed by definition ID.
+	// Definitions answered wrong stay due, so they're repeated within the same session.
+	Reviewed map[string]time.Time `json:"reviewed"`
+
+	// Box movement for every grade
+	Moves map[Grade]BoxMove `json:"moves"`
+
+	// Grades of all answers, keyed by definition ID
+	Reviews map[string][]Review `json:"reviews"`
+
 	BoxesInCurrentStage []*Box `json:"-"`
 
-	movements map[*Definition]int
+	// Definitions answered in the current stage, keyed by definition ID.
+	// They are put in their new boxes once the stage is over.
+	movements map[string]movement
 
 	CurrentDefinition *Definition `json:"-"`
 	CurrentBox        int         `json:"-"`
 }
 
+type movement struct {
+	definition Definition
+	boxNumber  int
+}
+
 func sortDefinitions(leitner *Leitner) {
-	for _, box := range leitner.Boxes {
+	sortBoxes(leitner.Boxes)
+}
+
+func sortBoxes(boxes []Box) {
+	for _, box := range boxes {
 		sort.Slice(box.Definitions, func(i, j int) bool {
 			return box.Definitions[i].To < box.Definitions[j].To
 		})
 	}
 }
 
+// Saved boxes include definitions waiting in movements and the one being asked (in its current box),
+// so the state can be saved after any answer without changing the session.
+func (leitner *Leitner) MarshalJSON() ([]byte, error) {
+	type plainLeitner Leitner
+
+	snapshot := plainLeitner(*leitner)
+	snapshot.Boxes = make([]Box, len(leitner.Boxes))
+
+	for i, box := range leitner.Boxes {
+		snapshot.Boxes[i] = Box{
+			BoxNumber:   box.BoxNumber,
+			Definitions: append([]Definition{}, box.Definitions...),
+		}
+	}
+
+	for _, movement := range leitner.movements {
+		box := &snapshot.Boxes[movement.boxNumber]
+		box.Definitions = append(box.Definitions, movement.definition)
+	}
+
+	if leitner.CurrentDefinition != nil {
+		box := &snapshot.Boxes[leitner.CurrentBox]
+		box.Definitions = append(box.Definitions, *leitner.CurrentDefinition)
+	}
+
+	sortBoxes(snapshot.Boxes)
+
+	return json.Marshal(snapshot)
+}
+
+// Schedule the definition to be put in the box when the stage is over.
+func (leitner *Leitner) moveTo(definition *Definition, boxNumber int) {
+	leitner.movements[definition.ID] = movement{
+		definition: *definition,
+		boxNumber:  boxNumber,
+	}
+}
+
 func (leitner *Leitner) move() {
-	for def, boxNumber := range leitner.movements {
-		box := &leitner.Boxes[boxNumber]
+	for _, movement := range leitner.movements {
+		box := &leitner.Boxes[movement.boxNumber]
 
-		box.Definitions = append(box.Definitions, *def)
+		box.Definitions = append(box.Definitions, movement.definition)
 	}
 
 	sortDefinitions(leitner)
 
-	leitner.movements = make(map[*Definition]int)
+	leitner.movements = make(map[string]movement)
+}
+
+func (leitner *Leitner) level(id string) int {
+	if movement, ok := leitner.movements[id]; ok {
+		return movement.boxNumber
+	}
+
+	if leitner.CurrentDefinition != nil && leitner.CurrentDefinition.ID == id {
+		return leitner.CurrentBox
+	}
+
+	for _, box := range leitner.Boxes {
+		for _, def := range box.Definitions {
+			if def.ID == id {
+				return box.BoxNumber
+			}
+		}
+	}
+
+	return -1
 }
 
+// When the definition in the box should be asked again.
+func (leitner *Leitner) dueDate(id string, boxNumber int) time.Time {
+	reviewed, ok := leitner.Reviewed[id]
+
+	if !ok {
+		return time.Time{}
+	}
+
+	return startOfDay(reviewed).AddDate(0, 0, leitner.Intervals[boxNumber])
+}
+
+func (leitner *Leitner) isDue(id string, boxNumber int) bool {
+	return !leitner.dueDate(id, boxNumber).After(now())
+}
+
+func (leitner *Leitner) nextDue() time.Time {
+	var earliest time.Time
+
+	for _, box := range leitner.Boxes {
+		for _, def := range box.Definitions {
+			if def.Suspended {
+				continue
+			}
+
+			due := leitner.dueDate(def.ID, box.BoxNumber)
+
+			if earliest.IsZero() || due.Before(earliest) {
+				earliest = due
+			}
+		}
+	}
+
+	return earliest
+}
+
+func (leitner *Leitner) dueDates() map[string]time.Time {
+	dates := make(map[string]time.Time)
+
+	for _, box := range leitner.Boxes {
+		for _, def := range box.Definitions {
+			dates[def.ID] = leitner.dueDate(def.ID, box.BoxNumber)
+		}
+	}
+
+	return dates
+}
+
+// Stage is empty if none of its definitions is due. Suspended definitions are never due.
 func (leitner *Leitner) isCurrentStageEmpty() bool {
 	if len(leitner.BoxesInCurrentStage) == 0 {
 		return true
 	}
 
 	for _, box := range leitner.BoxesInCurrentStage {
-		if len(box.Definitions) > 0 {
-			return false
+		for _, def := range box.Definitions {
+			if !def.Suspended && leitner.isDue(def.ID, box.BoxNumber) {
+				return false
+			}
 		}
 	}
 
@@ -149,14 +318,124 @@ func (leitner *Leitner) getDefinition() {
 	leitner.CurrentDefinition = nil
 
 	for _, box := range leitner.BoxesInCurrentStage {
-		if len(box.Definitions) > 0 {
+		for i, def := range box.Definitions {
+			if def.Suspended || !leitner.isDue(def.ID, box.BoxNumber) {
+				continue
+			}
+
 			leitner.CurrentBox = box.BoxNumber
-			leitner.CurrentDefinition = &box.Definitions[0]
-			box.Definitions = box.Definitions[1:]
+			leitner.CurrentDefinition = &def
+			box.Definitions = append(box.Definitions[:i], box.Definitions[i+1:]...)
 
-			break
+			return
+		}
+	}
+}
+
+func (leitner *Leitner) next() *Definition {
+	// Every stage is visited at most once, so an empty deck doesn't loop forever
+	for i := 0; i <= leitner.BoxCount; i++ {
+		if !leitner.isCurrentStageEmpty() {
+			leitner.getDefinition()
+
+			return leitner.CurrentDefinition
 		}
+
+		leitner.move()
+		leitner.maybeChangeStage()
+		leitner.setupStage()
 	}
+
+	return nil
+}
+
+func (leitner *Leitner) record(grade Grade) {
+	def := leitner.CurrentDefinition
+	move := leitner.Moves[grade]
+
+	nextBox := leitner.CurrentBox + move.Step
+
+	if move.Reset || nextBox < 0 {
+		nextBox = 0
+	}
+
+	if nextBox >= leitner.BoxCount {
+		nextBox = leitner.BoxCount - 1
+	}
+
+	leitner.moveTo(def, nextBox)
+
+	if grade != gradeAgain {
+		leitner.Reviewed[def.ID] = now()
+	}
+
+	leitner.Reviews[def.ID] = append(leitner.Reviews[def.ID], Review{
+		Time:  now(),
+		Grade: grade,
+	})
+
+	// It's in movements now, so it mustn't be saved twice
+	leitner.CurrentDefinition = nil
+}
+
+// Put the definition returned by the last call to next back at the end of its box, so it's asked later.
+func (leitner *Leitner) skip() {
+	if leitner.CurrentDefinition == nil {
+		return
+	}
+
+	box := &leitner.Boxes[leitner.CurrentBox]
+	box.Definitions = append(box.Definitions, *leitner.CurrentDefinition)
+
+	leitner.CurrentDefinition = nil
+}
+
+// Copy sharing nothing that changes while studying, e.g. to undo an answer.
+func (leitner *Leitner) clone() *Leitner {
+	clone := *leitner
+	clone.Boxes = make([]Box, len(leitner.Boxes))
+
+	for i, box := range leitner.Boxes {
+		clone.Boxes[i] = Box{
+			BoxNumber:   box.BoxNumber,
+			Definitions: append([]Definition{}, box.Definitions...),
+		}
+	}
+
+	clone.BoxesInCurrentStage = make([]*Box, len(leitner.BoxesInCurrentStage))
+
+	for i, box := range leitner.BoxesInCurrentStage {
+		for j := range leitner.Boxes {
+			if box == &leitner.Boxes[j] {
+				clone.BoxesInCurrentStage[i] = &clone.Boxes[j]
+			}
+		}
+	}
+
+	clone.Reviewed = make(map[string]time.Time)
+
+	for id, reviewed := range leitner.Reviewed {
+		clone.Reviewed[id] = reviewed
+	}
+
+	clone.Reviews = make(map[string][]Review)
+
+	for id, reviews := range leitner.Reviews {
+		clone.Reviews[id] = append([]Review{}, reviews...)
+	}
+
+	clone.movements = make(map[string]movement)
+
+	for id, movement := range leitner.movements {
+		clone.movements[id] = movement
+	}
+
+	if leitner.CurrentDefinition != nil {
+		def := *leitner.CurrentDefinition
+		clone.CurrentDefinition = &def
+	}
+
+	return &clone
 }
 
 func initLeitner(boxCount int, allDefinitions []Definition) *Leitner {
@@ -181,9 +460,164 @@ func initLeitner(boxCount int, allDefinitions []Definition) *Leitner {
 		Boxes:     boxes,
 		// Stage will get set to 0 automatically
 		Stage:               boxCount - 1,
+		Intervals:           getDefaultIntervals(boxCount),
+		Reviewed:            make(map[string]time.Time),
+		Moves:               getDefaultMoves(),
+		Reviews:             make(map[string][]Review),
 		BoxesInCurrentStage: make([]*Box, 0),
-		movements:           make(map[*Definition]int),
+		movements:           make(map[string]movement),
 		CurrentDefinition:   nil,
 		CurrentBox:          0,
 	}
 }
+
+// Change the number of boxes, spreading definitions proportionally over the new boxes,
+// e.g. going from 3 to 5 boxes puts definitions from the 2nd box in the 3rd one.
+// It also repairs histories whose boxes don't match the saved box count.
+// Returns true if any definitions had to be redistributed.
+func (leitner *Leitner) resize(boxCount int) bool {
+	if len(leitner.Intervals) != boxCount {
+		leitner.Intervals = getDefaultIntervals(boxCount)
+	}
+
+	if leitner.Stage >= boxCount || leitner.Stage < 0 {
+		leitner.Stage = boxCount - 1
+	}
+
+	if leitner.BoxCount == boxCount && len(leitner.Boxes) == boxCount {
+		return false
+	}
+
+	boxes := make([]Box, boxCount)
+
+	for i := range boxes {
+		boxes[i] = Box{
+			BoxNumber:   i,
+			Definitions: []Definition{},
+		}
+	}
+
+	oldCount := len(leitner.Boxes)
+
+	for i, box := range leitner.Boxes {
+		newBox := 0
+
+		if oldCount > 1 {
+			newBox = int(math.Round(float64(i*(boxCount-1)) / float64(oldCount-1)))
+		}
+
+		boxes[newBox].Definitions = append(boxes[newBox].Definitions, box.Definitions...)
+	}
+
+	leitner.BoxCount = boxCount
+	leitner.Boxes = boxes
+	leitner.Stage = boxCount - 1
+	leitner.BoxesInCurrentStage = make([]*Box, 0)
+
+	sortDefinitions(leitner)
+
+	return true
+}
+
+// Changes applied to the boxes when the deck file no longer matches its history.
+type Reconciliation struct {
+	Added   []Definition
+	Updated []Definition
+	Removed []Definition
+}
+
+func (reconciliation *Reconciliation) isEmpty() bool {
+	return len(reconciliation.Added) == 0 && len(reconciliation.Updated) == 0 && len(reconciliation.Removed) == 0
+}
+
+// Bring boxes loaded from the history file in line with definitions from the deck file.
+// Definitions are matched by ID: new ones go to the first box, edited ones keep their box,
+// the ones deleted from the deck file are dropped.
+func (leitner *Leitner) reconcile(definitions []Definition) *Reconciliation {
+	reconciliation := &Reconciliation{}
+
+	// Definitions answered in the current stage are matched in their new boxes, when the deck is edited while studying
+	if len(leitner.movements) > 0 {
+		leitner.move()
+	}
+
+	wanted := make(map[string]Definition)
+	// History files written before definitions had IDs are matched by content
+	legacy := make(map[Definition]string)
+
+	for _, def := range definitions {
+		wanted[def.ID] = def
+		legacy[Definition{From: def.From, To: def.To}] = def.ID
+	}
+
+	present := make(map[string]bool)
+
+	for i := range leitner.Boxes {
+		box := &leitner.Boxes[i]
+		kept := []Definition{}
+
+		for _, def := range box.Definitions {
+			if def.ID == "" {
+				def.ID = legacy[def]
+			}
+
+			current, ok := wanted[def.ID]
+
+			if !ok || present[def.ID] {
+				reconciliation.Removed = append(reconciliation.Removed, def)
+				continue
+			}
+
+			if current != def {
+				reconciliation.Updated = append(reconciliation.Updated, current)
+			}
+
+			present[def.ID] = true
+			kept = append(kept, current)
+		}
+
+		box.Definitions = kept
+	}
+
+	firstBox := &leitner.Boxes[0]
+
+	for _, def := range definitions {
+		if present[def.ID] {
+			continue
+		}
+
+		present[def.ID] = true
+		firstBox.Definitions = append(firstBox.Definitions, def)
+		reconciliation.Added = append(reconciliation.Added, def)
+	}
+
+	for id := range leitner.Reviewed {
+		if !present[id] {
+			delete(leitner.Reviewed, id)
+		}
+	}
+
+	for id := range leitner.Reviews {
+		if !present[id] {
+			delete(leitner.Reviews, id)
+		}
+	}
+
+	if leitner.Reviewed == nil {
+		leitner.Reviewed = make(map[string]time.Time)
+	}
+
+	if leitner.Reviews == nil {
+		leitner.Reviews = make(map[string][]Review)
+	}
+
+	if leitner.Moves == nil {
+		leitner.Moves = getDefaultMoves()
+	}
+
+	if leitner.movements == nil {
+		leitner.movements = make(map[string]movement)
+	}
+
+	return reconciliation
+}
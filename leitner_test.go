@@ -1,73 +1,87 @@
 package main
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 var defToGo Definition = Definition{
-	from: "andare",
-	to:   "to go",
+	ID:   "andare",
+	From: "andare",
+	To:   "to go",
 }
 
 var defToBe Definition = Definition{
-	from: "essere",
-	to:   "to be",
+	ID:   "essere",
+	From: "essere",
+	To:   "to be",
 }
 
 var defToSee Definition = Definition{
-	from: "vedere",
-	to:   "to see",
+	ID:   "vedere",
+	From: "vedere",
+	To:   "to see",
 }
 
 var defToSleep Definition = Definition{
-	from: "dormire",
-	to:   "to sleep",
+	ID:   "dormire",
+	From: "dormire",
+	To:   "to sleep",
 }
 
 var definitions []Definition = []Definition{
 	{
-		from: "andare",
-		to:   "to go",
+		ID:   "andare",
+		From: "andare",
+		To:   "to go",
 	},
 	{
-		from: "essere",
-		to:   "to be",
+		ID:   "essere",
+		From: "essere",
+		To:   "to be",
 	},
 	{
-		from: "vedere",
-		to:   "to see",
+		ID:   "vedere",
+		From: "vedere",
+		To:   "to see",
 	},
 	{
-		from: "dormire",
-		to:   "to sleep",
+		ID:   "dormire",
+		From: "dormire",
+		To:   "to sleep",
 	},
 }
 
 func getTestLeitner() *Leitner {
 
 	return &Leitner{
-		boxCount:  3,
-		sessionNo: 0,
-		boxes: []Box{
+		BoxCount:  3,
+		SessionNo: 0,
+		Boxes: []Box{
 			{
-				definitions: []Definition{
+				Definitions: []Definition{
 					{
-						from: "andare",
-						to:   "to go",
+						ID:   "andare",
+						From: "andare",
+						To:   "to go",
 					},
 					{
-						from: "essere",
-						to:   "to be",
+						ID:   "essere",
+						From: "essere",
+						To:   "to be",
 					},
 					{
-						from: "vedere",
-						to:   "to see",
+						ID:   "vedere",
+						From: "vedere",
+						To:   "to see",
 					},
 					{
-						from: "dormire",
-						to:   "to sleep",
+						ID:   "dormire",
+						From: "dormire",
+						To:   "to sleep",
 					},
 				},
 			},
@@ -78,24 +92,24 @@ func getTestLeitner() *Leitner {
 func TestInitLeitner(t *testing.T) {
 	leitner := initLeitner(3, definitions)
 
-	assert.Equal(t, len(leitner.boxes), 3)
+	assert.Equal(t, len(leitner.Boxes), 3)
 
-	box1 := &leitner.boxes[0]
-	box2 := &leitner.boxes[1]
-	box3 := &leitner.boxes[2]
+	box1 := &leitner.Boxes[0]
+	box2 := &leitner.Boxes[1]
+	box3 := &leitner.Boxes[2]
 
-	assert.Equal(t, 0, box1.boxNumber)
-	assert.Equal(t, 1, box2.boxNumber)
-	assert.Equal(t, 2, box3.boxNumber)
+	assert.Equal(t, 0, box1.BoxNumber)
+	assert.Equal(t, 1, box2.BoxNumber)
+	assert.Equal(t, 2, box3.BoxNumber)
 
-	assert.Equal(t, definitions, box1.definitions)
-	assert.Equal(t, []Definition(nil), box2.definitions)
-	assert.Equal(t, []Definition(nil), box3.definitions)
-	assert.Equal(t, leitner.boxCount-1, leitner.stage)
-	assert.Equal(t, make([]*Box, 0), leitner.boxesInCurrentStage)
-	assert.Equal(t, make(map[*Definition]int), leitner.movements)
-	assert.Equal(t, (*Definition)(nil), leitner.currentDefinition)
-	assert.Equal(t, 0, leitner.currentBox)
+	assert.Equal(t, definitions, box1.Definitions)
+	assert.Equal(t, []Definition{}, box2.Definitions)
+	assert.Equal(t, []Definition{}, box3.Definitions)
+	assert.Equal(t, leitner.BoxCount-1, leitner.Stage)
+	assert.Equal(t, make([]*Box, 0), leitner.BoxesInCurrentStage)
+	assert.Equal(t, make(map[string]movement), leitner.movements)
+	assert.Equal(t, (*Definition)(nil), leitner.CurrentDefinition)
+	assert.Equal(t, 0, leitner.CurrentBox)
 }
 
 func TestIsCurrentStageEmpty_initial_state(t *testing.T) {
@@ -107,8 +121,8 @@ func TestIsCurrentStageEmpty_initial_state(t *testing.T) {
 func TestIsCurrentStageEmpty_no_definitions_in_boxes(t *testing.T) {
 	leitner := initLeitner(3, definitions)
 
-	leitner.boxesInCurrentStage = append(leitner.boxesInCurrentStage, &Box{
-		definitions: []Definition{},
+	leitner.BoxesInCurrentStage = append(leitner.BoxesInCurrentStage, &Box{
+		Definitions: []Definition{},
 	})
 
 	assert.True(t, leitner.isCurrentStageEmpty())
@@ -117,8 +131,8 @@ func TestIsCurrentStageEmpty_no_definitions_in_boxes(t *testing.T) {
 func TestIsCurrentStageEmpty_definition_in_box(t *testing.T) {
 	leitner := initLeitner(3, definitions)
 
-	leitner.boxesInCurrentStage = append(leitner.boxesInCurrentStage, &Box{
-		definitions: []Definition{
+	leitner.BoxesInCurrentStage = append(leitner.BoxesInCurrentStage, &Box{
+		Definitions: []Definition{
 			defToSleep,
 		},
 	})
@@ -129,19 +143,19 @@ func TestIsCurrentStageEmpty_definition_in_box(t *testing.T) {
 func TestMove(t *testing.T) {
 	leitner := initLeitner(3, []Definition{})
 
-	leitner.movements[&defToBe] = 0
-	leitner.movements[&defToSleep] = 1
-	leitner.movements[&defToGo] = 2
-	leitner.movements[&defToSee] = 2
+	leitner.moveTo(&defToBe, 0)
+	leitner.moveTo(&defToSleep, 1)
+	leitner.moveTo(&defToGo, 2)
+	leitner.moveTo(&defToSee, 2)
 
 	leitner.move()
 
-	assert.Equal(t, []Definition{defToBe}, leitner.boxes[0].definitions)
-	assert.Equal(t, []Definition{defToSleep}, leitner.boxes[1].definitions)
+	assert.Equal(t, []Definition{defToBe}, leitner.Boxes[0].Definitions)
+	assert.Equal(t, []Definition{defToSleep}, leitner.Boxes[1].Definitions)
 
 	actual := make(map[Definition]struct{})
 
-	for _, def := range leitner.boxes[2].definitions {
+	for _, def := range leitner.Boxes[2].Definitions {
 		actual[def] = struct{}{}
 	}
 
@@ -156,26 +170,26 @@ func TestMove(t *testing.T) {
 func TestNextBox(t *testing.T) {
 	leitner := initLeitner(3, definitions)
 
-	box1 := &leitner.boxes[0]
-	box2 := &leitner.boxes[1]
-	box3 := &leitner.boxes[2]
+	box1 := &leitner.Boxes[0]
+	box2 := &leitner.Boxes[1]
+	box3 := &leitner.Boxes[2]
 
 	assert.Equal(t, box1, leitner.getBox(1))
-	leitner.sessionNo++
+	leitner.SessionNo++
 
 	// Still box1, as other boxes are empty
 	assert.Equal(t, box1, leitner.getBox(1))
 
-	box2.definitions = append(box2.definitions, defToBe)
-	box3.definitions = append(box2.definitions, defToBe)
+	box2.Definitions = append(box2.Definitions, defToBe)
+	box3.Definitions = append(box2.Definitions, defToBe)
 
-	leitner.sessionNo++
+	leitner.SessionNo++
 	assert.Equal(t, box2, leitner.getBox(1))
 
-	leitner.sessionNo++
+	leitner.SessionNo++
 	assert.Equal(t, box3, leitner.getBox(1))
 
-	leitner.sessionNo++
+	leitner.SessionNo++
 	assert.Equal(t, box1, leitner.getBox(1))
 }
 
@@ -189,22 +203,22 @@ func TestNextBox__not_getting_into_endless_loop(t *testing.T) {
 // func TestGetNextBox(t *testing.T) {
 // leitner := initLeitner(3, definitions)
 
-// box1 := &leitner.boxes[0]
-// box2 := &leitner.boxes[1]
-// box3 := &leitner.boxes[2]
+// box1 := &leitner.Boxes[0]
+// box2 := &leitner.Boxes[1]
+// box3 := &leitner.Boxes[2]
 
 // No other boxes have definitions, so it's still box1
 // assert.Equal(t, box1, leitner.getNextBox())
 // assert.Equal(t, box1, leitner.getPreviousBox())
 
-// box2.definitions = append(box2.definitions, defToBe)
+// box2.Definitions = append(box2.Definitions, defToBe)
 
 // assert.Equal(t, box2, leitner.getNextBox())
 // assert.Equal(t, box1, leitner.getNextBox())
 // assert.Equal(t, box2, leitner.getPreviousBox())
 // assert.Equal(t, box1, leitner.getPreviousBox())
 
-// box3.definitions = append(box3.definitions, defToBe)
+// box3.Definitions = append(box3.Definitions, defToBe)
 
 // assert.Equal(t, box2, leitner.getNextBox())
 // assert.Equal(t, box3, leitner.getNextBox())
@@ -214,3 +228,277 @@ func TestNextBox__not_getting_into_endless_loop(t *testing.T) {
 // assert.Equal(t, box1, leitner.getPreviousBox())
 // assert.Equal(t, box3, leitner.getPreviousBox())
 // }
+
+func TestReconcile(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+
+	leitner.Boxes[0].Definitions = []Definition{defToGo}
+	leitner.Boxes[2].Definitions = []Definition{defToBe}
+
+	reconciliation := leitner.reconcile([]Definition{defToBe, defToSee})
+
+	assert.Equal(t, []Definition{defToSee}, leitner.Boxes[0].Definitions)
+	assert.Equal(t, []Definition{}, leitner.Boxes[1].Definitions)
+	assert.Equal(t, []Definition{defToBe}, leitner.Boxes[2].Definitions)
+	assert.Equal(t, []Definition{defToSee}, reconciliation.Added)
+	assert.Equal(t, []Definition{defToGo}, reconciliation.Removed)
+}
+
+func TestReconcile_edited_answer_keeps_box(t *testing.T) {
+	leitner := initLeitner(3, []Definition{})
+
+	leitner.Boxes[2].Definitions = []Definition{defToGo}
+
+	edited := Definition{ID: "andare", From: "andare", To: "to walk"}
+	reconciliation := leitner.reconcile([]Definition{edited})
+
+	assert.Equal(t, []Definition{edited}, leitner.Boxes[2].Definitions)
+	assert.Equal(t, []Definition{edited}, reconciliation.Updated)
+	assert.Equal(t, []Definition(nil), reconciliation.Added)
+	assert.Equal(t, []Definition(nil), reconciliation.Removed)
+}
+
+func TestReconcile_history_without_ids(t *testing.T) {
+	leitner := initLeitner(3, []Definition{})
+
+	leitner.Boxes[1].Definitions = []Definition{{From: "andare", To: "to go"}}
+
+	reconciliation := leitner.reconcile([]Definition{defToGo})
+
+	assert.True(t, reconciliation.isEmpty())
+	assert.Equal(t, []Definition{defToGo}, leitner.Boxes[1].Definitions)
+}
+
+func TestReconcile_no_changes(t *testing.T) {
+	leitner := initLeitner(3, definitions)
+
+	reconciliation := leitner.reconcile(definitions)
+
+	assert.True(t, reconciliation.isEmpty())
+	assert.Equal(t, definitions, leitner.Boxes[0].Definitions)
+}
+
+func TestResize_more_boxes(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo})
+
+	leitner.Boxes[1].Definitions = []Definition{defToBe}
+	leitner.Boxes[2].Definitions = []Definition{defToSee}
+
+	assert.True(t, leitner.resize(5))
+
+	assert.Equal(t, 5, leitner.BoxCount)
+	assert.Equal(t, 4, leitner.Stage)
+	assert.Equal(t, []Definition{defToGo}, leitner.Boxes[0].Definitions)
+	assert.Equal(t, []Definition{}, leitner.Boxes[1].Definitions)
+	assert.Equal(t, []Definition{defToBe}, leitner.Boxes[2].Definitions)
+	assert.Equal(t, []Definition{}, leitner.Boxes[3].Definitions)
+	assert.Equal(t, []Definition{defToSee}, leitner.Boxes[4].Definitions)
+
+	for i, box := range leitner.Boxes {
+		assert.Equal(t, i, box.BoxNumber)
+	}
+}
+
+func TestResize_fewer_boxes(t *testing.T) {
+	leitner := initLeitner(5, []Definition{defToGo})
+
+	leitner.Boxes[1].Definitions = []Definition{defToBe}
+	leitner.Boxes[3].Definitions = []Definition{defToSee}
+	leitner.Boxes[4].Definitions = []Definition{defToSleep}
+
+	assert.True(t, leitner.resize(2))
+
+	assert.Equal(t, []Definition{defToBe, defToGo}, leitner.Boxes[0].Definitions)
+	assert.Equal(t, []Definition{defToSee, defToSleep}, leitner.Boxes[1].Definitions)
+}
+
+func TestResize_same_box_count(t *testing.T) {
+	leitner := initLeitner(3, definitions)
+
+	assert.False(t, leitner.resize(3))
+	assert.Equal(t, definitions, leitner.Boxes[0].Definitions)
+}
+
+func TestResize_repairs_stage(t *testing.T) {
+	leitner := initLeitner(3, definitions)
+	leitner.Stage = 7
+
+	leitner.resize(3)
+
+	assert.Equal(t, 2, leitner.Stage)
+}
+
+func TestGetDefaultIntervals(t *testing.T) {
+	assert.Equal(t, []int{1, 3, 7}, getDefaultIntervals(3))
+	assert.Equal(t, []int{1, 3, 7, 14, 30, 60, 120, 240, 480, 960}, getDefaultIntervals(10))
+}
+
+func TestLeitner_asks_only_due_definitions(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	restore := setNow(day)
+	defer restore()
+
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+
+	assert.Equal(t, &defToBe, leitner.next())
+	leitner.record(gradeGood)
+	assert.Equal(t, &defToGo, leitner.next())
+	leitner.record(gradeAgain)
+
+	// Answered wrong, so it's still due
+	assert.Equal(t, &defToGo, leitner.next())
+	leitner.record(gradeGood)
+
+	// Both definitions are in the 2nd box now, with a 3 day interval
+	assert.Nil(t, leitner.next())
+	assert.Equal(t, time.Date(2020, 5, 13, 0, 0, 0, 0, time.UTC), leitner.nextDue())
+
+	setNow(day.AddDate(0, 0, 2))
+	assert.Nil(t, leitner.next())
+
+	setNow(day.AddDate(0, 0, 3))
+	assert.NotNil(t, leitner.next())
+}
+
+func TestLeitner_next_empty_deck(t *testing.T) {
+	leitner := initLeitner(3, []Definition{})
+
+	assert.Nil(t, leitner.next())
+	assert.True(t, leitner.nextDue().IsZero())
+}
+
+func TestReconcile_forgets_review_times_of_removed_definitions(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+	leitner.Reviewed["andare"] = time.Now()
+	leitner.Reviewed["essere"] = time.Now()
+
+	leitner.reconcile([]Definition{defToGo})
+
+	_, ok := leitner.Reviewed["essere"]
+
+	assert.False(t, ok)
+	assert.Equal(t, 1, len(leitner.Reviewed))
+}
+
+func TestLeitnerRecord_grades(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	leitner := initLeitner(5, []Definition{})
+
+	record := func(boxNumber int, grade Grade) int {
+		leitner.CurrentDefinition = &defToGo
+		leitner.CurrentBox = boxNumber
+		leitner.record(grade)
+
+		return leitner.movements["andare"].boxNumber
+	}
+
+	assert.Equal(t, 0, record(3, gradeAgain))
+	assert.Equal(t, 3, record(3, gradeHard))
+	assert.Equal(t, 4, record(3, gradeGood))
+	assert.Equal(t, 3, record(1, gradeEasy))
+	assert.Equal(t, 4, record(3, gradeEasy))
+
+	leitner.Moves[gradeHard] = BoxMove{Step: -1}
+
+	assert.Equal(t, 2, record(3, gradeHard))
+
+	assert.Equal(t, 6, len(leitner.Reviews["andare"]))
+	assert.Equal(t, Review{Time: day, Grade: gradeAgain}, leitner.Reviews["andare"][0])
+}
+
+func TestLeitnerMarshalJSON_saves_pending_definitions(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe, defToSee})
+	leitner.CurrentDefinition = &defToSee
+	leitner.CurrentBox = 0
+	leitner.Boxes[0].Definitions = []Definition{defToBe}
+	leitner.moveTo(&defToGo, 1)
+
+	data, err := json.Marshal(leitner)
+	assert.Nil(t, err)
+
+	var saved Leitner
+	assert.Nil(t, json.Unmarshal(data, &saved))
+
+	assert.Equal(t, []Definition{defToBe, defToSee}, saved.Boxes[0].Definitions)
+	assert.Equal(t, []Definition{defToGo}, saved.Boxes[1].Definitions)
+	assert.Equal(t, []Definition{}, saved.Boxes[2].Definitions)
+
+	// The session itself is left as it was
+	assert.Equal(t, []Definition{defToBe}, leitner.Boxes[0].Definitions)
+	assert.Equal(t, &defToSee, leitner.CurrentDefinition)
+	assert.Len(t, leitner.movements, 1)
+}
+
+func TestLeitnerLevel(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+	leitner.Intervals = []int{0, 0, 0}
+
+	assert.Equal(t, 0, leitner.level("andare"))
+	assert.Equal(t, -1, leitner.level("vedere"))
+
+	def := leitner.next()
+	assert.Equal(t, 0, leitner.level(def.ID))
+
+	leitner.record(gradeEasy)
+	assert.Equal(t, 2, leitner.level(def.ID))
+}
+
+func TestLeitner_leaves_out_suspended_definitions(t *testing.T) {
+	suspended := Definition{ID: "essere", From: "essere", To: "to be", Suspended: true}
+	leitner := initLeitner(3, []Definition{defToGo, suspended})
+
+	assert.Equal(t, &defToGo, leitner.next())
+	leitner.record(gradeGood)
+
+	assert.Nil(t, leitner.next())
+	assert.Equal(t, 0, leitner.level("essere"))
+}
+
+func TestLeitnerSkip(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+
+	assert.Equal(t, &defToBe, leitner.next())
+	leitner.skip()
+
+	assert.Nil(t, leitner.CurrentDefinition)
+	assert.Equal(t, &defToGo, leitner.next())
+	leitner.record(gradeGood)
+
+	assert.Equal(t, &defToBe, leitner.next())
+}
+
+func TestLeitnerClone(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+	leitner.next()
+
+	clone := leitner.clone()
+	leitner.record(gradeGood)
+	leitner.next()
+
+	assert.Equal(t, &defToBe, clone.CurrentDefinition)
+	assert.Empty(t, clone.movements)
+	assert.Empty(t, clone.Reviews)
+	assert.Equal(t, []Definition{defToGo}, clone.Boxes[0].Definitions)
+	assert.Equal(t, &clone.Boxes[0], clone.BoxesInCurrentStage[0])
+
+	// The clone goes on from where it was
+	clone.record(gradeAgain)
+	assert.Equal(t, 0, clone.level("essere"))
+	assert.Equal(t, 1, leitner.level("essere"))
+}
+
+func TestReconcile_during_stage(t *testing.T) {
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+
+	leitner.next()
+	leitner.record(gradeGood)
+
+	edited := Definition{ID: "essere", From: "essere", To: "to be | being"}
+	reconciliation := leitner.reconcile([]Definition{defToGo, edited})
+
+	assert.Equal(t, []Definition{edited}, reconciliation.Updated)
+	assert.Empty(t, reconciliation.Added)
+	assert.Equal(t, 1, leitner.level("essere"))
+}
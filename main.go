@@ -3,12 +3,13 @@ package main
 import (
 	"bufio"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/rand"
 	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,18 +19,70 @@ import (
 type Session struct {
 	correctAnswers int
 	wrongAnswers   int
+
+	decks []*StudyDeck
+
+	// Index of the deck whose turn it is to ask a question
+	turn int
+
+	// Held while the deck changes, so the session isn't saved half-way through an update
+	mutex sync.Mutex
+}
+
+const (
+	// Question is the definition's first block, answer the second
+	directionForward  = "forward"
+	directionReversed = "reversed"
+)
+
+// How answers are given
+const (
+	// The answer is typed
+	modeTyped = "typed"
+	// The answer is picked from numbered options
+	modeChoice = "choice"
+	// The answer is shown on request and the user says whether they knew it
+	modeFlip = "flip"
+)
+
+func isValidMode(mode string) bool {
+	return mode == modeTyped || mode == modeChoice || mode == modeFlip
+}
+
+// A definition as it's asked in the session.
+type Question struct {
+	Definition Definition
+	Text       string
+	Answer     string
+	Direction  string
 }
 
 type CommandLine struct {
 	debug         *bool
-	deckPath      *string
+	deckPaths     []string
+	boxes         *int
+	algorithm     *string
+	accents       *string
+	backups       *int
 	order         *string
+	mode          *string
+	choices       *int
+	tui           *bool
 	convertFromKV *string
 }
 
 func printDebug(deck *Deck) {
-	leitner := deck.Leitner
+	switch scheduler := deck.scheduler().(type) {
+	case *Leitner:
+		printLeitnerDebug(scheduler)
+	case *SM2:
+		printSM2Debug(scheduler)
+	case *FSRS:
+		printFSRSDebug(scheduler)
+	}
+}
 
+func printLeitnerDebug(leitner *Leitner) {
 	fmt.Printf("boxes in stage %d:\n", leitner.Stage)
 
 	for _, box := range leitner.BoxesInCurrentStage {
@@ -43,46 +96,94 @@ func printDebug(deck *Deck) {
 
 	fmt.Println("Movements")
 
-	for def, boxNumber := range deck.Leitner.movements {
-		fmt.Printf("\t%s -> %d\n", def, boxNumber)
+	for id, movement := range leitner.movements {
+		fmt.Printf("\t%s %s -> %d\n", id, movement.definition.From, movement.boxNumber)
+	}
+}
+
+func printSM2Debug(sm2 *SM2) {
+	for _, card := range sm2.sortedCards() {
+		fmt.Printf("\t%s %s\tdue: %s\tinterval: %d\tEF: %0.2f\trepetitions: %d\n",
+			card.Definition.ID, card.Definition.From, card.Due.Format("2006-01-02"),
+			card.Interval, card.EaseFactor, card.Repetitions)
+	}
+
+	fmt.Println("Relearning")
+
+	for id := range sm2.relearning {
+		fmt.Printf("\t%s\n", id)
+	}
+}
+
+func printFSRSDebug(fsrs *FSRS) {
+	for _, card := range fsrs.sortedCards() {
+		fmt.Printf("\t%s %s\tdue: %s\tstability: %0.2f\tdifficulty: %0.2f\treviews: %d\n",
+			card.Definition.ID, card.Definition.From, card.Due.Format("2006-01-02"),
+			card.Stability, card.Difficulty, len(card.Reviews))
+	}
+
+	fmt.Println("Relearning")
+
+	for id := range fsrs.relearning {
+		fmt.Printf("\t%s\n", id)
 	}
 }
 
-func readCommandLine() *CommandLine {
+func readCommandLine(args []string) *CommandLine {
+	flags := newFlagSet("study", "-deck-path <deck> [flags]")
+
 	command := CommandLine{}
-	command.debug = flag.Bool("debug", false, "Debug mode")
-	command.deckPath = flag.String("deck-path", "", "Path to deck file")
-	command.order = flag.String("order", "standard", "Question or answer first (standard, reversed, random")
-	command.convertFromKV = flag.String("convert-from-kv", "", "Convert file from key-value pairs to deck")
+	command.debug = flags.Bool("debug", false, "Debug mode")
+	deckPath := flags.String("deck-path", "", "Path to deck file, a directory of decks or a glob; more decks can follow the flags")
+	command.boxes = flags.Int("boxes", 0, "Number of Leitner boxes (overrides the deck file header)")
+	command.algorithm = flags.String("algorithm", "", "Scheduling algorithm (leitner, sm2, fsrs), Leitner unless set in the deck file or history")
+	command.accents = flags.String("accents", "", "Accept answers without diacritics (lenient) or not (strict), lenient unless set in the deck file")
+	command.backups = flags.Int("backups", defaultBackupCount, "Number of history backups to keep, one is made when a session starts (0 turns them off)")
+	command.order = flags.String("order", "standard", "Question or answer first (standard, reversed, random)")
+	command.mode = flags.String("mode", modeTyped, "Type the answer (typed), pick it from numbered options (choice) or reveal it and say whether you knew it (flip)")
+	command.choices = flags.Int("choices", minChoices, "Number of options in choice mode, from 4 to 6")
+	command.tui = flags.Bool("tui", true, "Full-screen interface when studying in a terminal, questions are printed line by line otherwise")
+	command.convertFromKV = flags.String("convert-from-kv", "", "Convert file from key-value pairs to deck (same as the convert command)")
+
+	flags.Parse(args)
+
+	if *deckPath != "" {
+		command.deckPaths = append(command.deckPaths, *deckPath)
+	}
 
-	flag.Parse()
+	command.deckPaths = append(command.deckPaths, flags.Args()...)
 
 	return &command
 }
 
-func getQuestionAnswer(cmd *CommandLine, def *Definition) (string, string) {
+// Returns the question, the answer and the direction they're asked in.
+func getQuestionAnswer(cmd *CommandLine, def *Definition) (string, string, string) {
+	if def.Cloze {
+		return def.From, def.To, directionForward
+	}
+
+	// Half of a bidirectional card, already stored the way round it's asked
+	if def.Direction != "" {
+		return def.From, def.To, def.Direction
+	}
+
 	if *cmd.order == "reversed" {
-		return def.To, def.From
+		return def.To, def.From, directionReversed
 	}
 
 	if *cmd.order == "random" {
 		if rand.Float32() < 0.5 {
-			return def.To, def.From
+			return def.To, def.From, directionReversed
 		} else {
-			return def.From, def.To
+			return def.From, def.To, directionForward
 		}
 	}
 
 	// order == 'standard'
-	return def.From, def.To
+	return def.From, def.To, directionForward
 }
 
 func saveDeck(deck *Deck, deckPath string) {
-	leitner := deck.Leitner
-
-	leitner.movements[leitner.CurrentDefinition] = 0
-	leitner.move()
-
 	file, err := json.MarshalIndent(deck, "", " ")
 
 	if err != nil {
@@ -90,141 +191,506 @@ func saveDeck(deck *Deck, deckPath string) {
 		return
 	}
 
-	err = ioutil.WriteFile(fmt.Sprintf("%s.history.json", deckPath), file, 0644)
+	err = writeFileAtomic(historyPath(deckPath), file, 0644)
 
 	if err != nil {
 		fmt.Printf("Cannot save the deck history file %s\n", err)
 	}
 }
 
-func setupEndOfSessionHandler(session *Session, deck *Deck, deckPath string) {
+func printReconciliation(reconciliation *Reconciliation) {
+	if reconciliation.isEmpty() {
+		return
+	}
+
+	fmt.Println(aurora.Blue("Deck file changed since the last session"))
+
+	for _, def := range reconciliation.Added {
+		fmt.Printf("\t%s %s -> %s\n", aurora.Green("+"), formatOneLine(def.From), formatOneLine(def.To))
+	}
+
+	for _, def := range reconciliation.Updated {
+		fmt.Printf("\t%s %s -> %s\n", aurora.Yellow("~"), formatOneLine(def.From), formatOneLine(def.To))
+	}
+
+	for _, def := range reconciliation.Removed {
+		fmt.Printf("\t%s %s -> %s\n", aurora.Red("-"), formatOneLine(def.From), formatOneLine(def.To))
+	}
+
+	fmt.Printf("\tAdded: %d, updated: %d, removed: %d\n\n",
+		len(reconciliation.Added), len(reconciliation.Updated), len(reconciliation.Removed))
+}
+
+func printNothingDue(due time.Time) {
+	if due.IsZero() {
+		fmt.Println("Nothing to study, the deck is empty")
+		return
+	}
+
+	fmt.Printf("Nothing due until %s\n", due.Format("Mon, 02 Jan 2006"))
+}
+
+func endSession(session *Session) {
+	// Never unlocked, the process exits
+	session.mutex.Lock()
+	restoreTerminal()
+
+	fmt.Println(aurora.Blue("\nSession summary"))
+
+	fmt.Printf("\tCorrect: %d\n", session.correctAnswers)
+	fmt.Printf("\tWrong: %d\n", session.wrongAnswers)
+
+	total := session.correctAnswers + session.wrongAnswers
+
+	if total != 0 {
+		fmt.Printf("\tPct: %0.2f%%\n", float64(session.correctAnswers)/float64(total)*100)
+	}
+
+	if len(session.decks) > 1 {
+		printDeckResults(session.decks)
+	}
+
+	entries := []ReviewLogEntry{}
+
+	for _, study := range session.decks {
+		if deckEntries, err := loadReviewLog(reviewLogPath(study.path)); err == nil {
+			entries = append(entries, withoutUndone(deckEntries)...)
+		}
+	}
+
+	if len(entries) > 0 {
+		current, _ := streaks(reviewsPerDay(entries))
+		fmt.Printf("\tStreak: %s\n", formatDays(current))
+	}
+
+	for _, study := range session.decks {
+		saveDeck(study.deck, study.path)
+	}
+
+	os.Exit(0)
+}
+
+// Returns the channel the signals are delivered to, e.g. to stop listening to some of them for a while.
+func setupEndOfSessionHandler(session *Session) chan os.Signal {
 	c := make(chan os.Signal, 2)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	go func() {
 		<-c
-		fmt.Println(aurora.Blue("\nSession summary"))
+		endSession(session)
+	}()
 
-		fmt.Printf("\tCorrect: %d\n", session.correctAnswers)
-		fmt.Printf("\tWrong: %d\n", session.wrongAnswers)
+	return c
+}
+
+// Returns nil if there's nothing left to study.
+func prepareQuestion(command *CommandLine, deck *Deck) *Question {
+	def := deck.scheduler().next()
+
+	if def == nil {
+		return nil
+	}
 
-		total := session.correctAnswers + session.wrongAnswers
+	if *command.debug {
+		printDebug(deck)
+	}
 
-		if total != 0 {
-			fmt.Printf("\tPct: %0.2f%%\n", float64(session.correctAnswers)/float64(total)*100)
+	question := &Question{
+		Definition: *def,
+	}
+	question.Text, question.Answer, question.Direction = getQuestionAnswer(command, def)
+
+	return question
+}
+
+// Read the answer, typed or picked from options in choice mode. Returns false if the input is closed.
+// Choice mode falls back to typing when the deck has too few other answers to pick wrong options from.
+func askAnswer(input *bufio.Scanner, command *CommandLine, deck *Deck, question *Question) (string, Match, bool) {
+	if *command.mode == modeChoice {
+		if distractors := choiceDistractors(deck, question, *command.choices); distractors != nil {
+			options, correct := buildChoices(question, distractors)
+
+			return askChoice(input, options, correct)
 		}
 
-		saveDeck(deck, deckPath)
+		fmt.Printf("%s\n", aurora.Yellow(typedChoiceReason(*command.choices)))
+	}
+
+	fmt.Printf("%s:\n", aurora.Yellow("Answer"))
 
-		os.Exit(0)
-	}()
+	if !input.Scan() {
+		return "", matchWrong, false
+	}
+
+	return input.Text(), checkAnswer(input.Text(), question.Answer, deck.matcher()), true
+}
+
+// Show whether the answer is correct. Markup in the correct answer doesn't have to be typed.
+func checkAnswer(userAnswer string, correctAnswer string, matcher Matcher) Match {
+	match, feedback := describeAnswer(userAnswer, correctAnswer, matcher)
+	fmt.Print(feedback)
+
+	return match
 }
 
-func prepareQuestion(command *CommandLine, deck *Deck) (bool, string, string) {
-	leitner := deck.Leitner
+// Whether the answer is correct and the feedback shown for it.
+func describeAnswer(userAnswer string, correctAnswer string, matcher Matcher) (Match, string) {
+	var feedback strings.Builder
 
-	if leitner.isCurrentStageEmpty() {
-		leitner.move()
-		leitner.maybeChangeStage()
-		leitner.setupStage()
+	match, alternative := matcher.matchAlternatives(userAnswer, plainText(correctAnswer))
 
-		if leitner.isCurrentStageEmpty() {
-			return true, "", ""
+	switch match {
+	case matchExact:
+		fmt.Fprintf(&feedback, "\n%s\n\n", aurora.Green("============ CORRECT ============"))
+	case matchMissingAccents:
+		_, correct := highlightTypos(userAnswer, alternative)
+
+		fmt.Fprintf(&feedback, "\n%s\n\n", aurora.Green("============ CORRECT ============"))
+		fmt.Fprintf(&feedback, "%s:\n%s\n\n", aurora.Yellow("Mind the accents"), correct)
+	case matchAlmost:
+		answer, correct := highlightTypos(userAnswer, alternative)
+
+		fmt.Fprintf(&feedback, "\n%s\n\n", aurora.Yellow("============ ALMOST ============"))
+		fmt.Fprintf(&feedback, "%s:\n%s\n\n", aurora.Blue("Your answer"), answer)
+		fmt.Fprintf(&feedback, "%s:\n%s\n\n", aurora.Blue("Correct answer"), correct)
+	default:
+		alternatives := getAlternatives(correctAnswer)
+
+		fmt.Fprintf(&feedback, "\n%s\n\n", aurora.Red("============ WRONG ============"))
+
+		if len(alternatives) > 1 {
+			fmt.Fprintf(&feedback, "%s:\n%s\n\n", aurora.Blue("Correct answers"), renderMarkup(strings.Join(alternatives, "\n")))
+		} else {
+			fmt.Fprintf(&feedback, "%s:\n%s\n\n", aurora.Blue("Correct answer"), renderMarkup(formatAlternatives(correctAnswer)))
 		}
 	}
 
-	if *command.debug {
-		printDebug(deck)
+	return match, feedback.String()
+}
+
+// Mark characters that differ between the answer and the correct answer.
+func highlightTypos(userAnswer string, correctAnswer string) (string, string) {
+	var answer, correct strings.Builder
+
+	steps := alignAnswers([]rune(normalizeSpaces(userAnswer)), []rune(normalizeSpaces(correctAnswer)))
+
+	for _, step := range steps {
+		switch step.operation {
+		case diffEqual:
+			answer.WriteRune(step.answer)
+			correct.WriteRune(step.correct)
+		case diffSubstitute:
+			answer.WriteString(aurora.Red(string(step.answer)).Underline().String())
+			correct.WriteString(aurora.Green(string(step.correct)).Underline().String())
+		case diffInsert:
+			answer.WriteString(aurora.Red(string(step.answer)).Underline().String())
+		case diffDelete:
+			correct.WriteString(aurora.Green(string(step.correct)).Underline().String())
+		}
 	}
 
-	leitner.getDefinition()
+	return answer.String(), correct.String()
+}
 
-	question, answer := getQuestionAnswer(command, leitner.CurrentDefinition)
+// Ask for a self-assessment of the answer, empty input accepts the suggested grade.
+func readGrade(input *bufio.Scanner, suggested Grade) Grade {
+	for true {
+		fmt.Printf("%s: [1] again [2] hard [3] good [4] easy (Enter for %s)\n", aurora.Yellow("Grade"), suggested)
 
-	return false, question, answer
-}
+		if !input.Scan() {
+			break
+		}
 
-func recordAnswer(userAnswer string, correctAnswer string, session *Session, leitner *Leitner) {
-	def := leitner.CurrentDefinition
+		text := strings.ToLower(strings.TrimSpace(input.Text()))
 
-	if userAnswer == correctAnswer {
-		nextBox := leitner.CurrentBox + 1
-		if nextBox >= leitner.BoxCount {
-			nextBox = leitner.BoxCount - 1
+		if text == "" {
+			break
 		}
-		leitner.movements[def] = nextBox
 
-		session.correctAnswers++
-		fmt.Printf("\n%s\n\n", aurora.Green("============ CORRECT ============"))
-	} else {
-		prevBox := leitner.CurrentBox - 1
-		if prevBox < 0 {
-			prevBox = 0
+		if grade, ok := parseGrade(text); ok {
+			return grade
 		}
-		leitner.movements[def] = prevBox
+	}
+
+	return suggested
+}
+
+// Record the answer in the schedule and in the review log, which fills in how the answer moved the definition.
+// Returns the entry as it's written to the review log.
+func logAnswer(entry ReviewLogEntry, session *Session, study *StudyDeck) ReviewLogEntry {
+	scheduler := study.deck.scheduler()
 
+	entry.Algorithm = study.deck.Algorithm
+	entry.BoxBefore = scheduler.level(entry.CardID)
+	recordAnswer(entry.Grade, session, scheduler)
+	entry.BoxAfter = scheduler.level(entry.CardID)
+
+	if entry.Grade == gradeAgain {
+		study.wrongAnswers++
+	} else {
+		study.correctAnswers++
+	}
+
+	if err := appendReviewLog(reviewLogPath(study.path), entry); err != nil {
+		fmt.Printf("Cannot write the review log %s\n", err)
+	}
+
+	return entry
+}
+
+func recordAnswer(grade Grade, session *Session, scheduler Scheduler) {
+	scheduler.record(grade)
+
+	if grade == gradeAgain {
 		session.wrongAnswers++
+	} else {
+		session.correctAnswers++
+	}
+}
+
+// Load the deck file and merge it with the progress saved in its history file.
+func openDeck(command *CommandLine, deckPath string) (*Deck, error) {
+	data, err := loadFile(deckPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("file '%s' does not exist", deckPath)
+	}
+
+	deck, err := loadDeck(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the deck file '%s': %s", deckPath, err)
+	}
+
+	deck.shuffle()
+
+	if *command.accents != "" {
+		deck.Options.Accents = *command.accents
+	}
+
+	boxCount := deck.Options.BoxCount
+
+	if *command.boxes > 0 {
+		boxCount = *command.boxes
+	}
+
+	algorithm := deck.Options.Algorithm
+
+	if *command.algorithm != "" {
+		algorithm = *command.algorithm
+	}
+
+	history, err := loadHistory(historyPath(deckPath))
+
+	if err != nil {
+		return nil, fmt.Errorf("cannot load the deck history file %s", err)
+	}
+
+	reconciliations := make(map[string]*Reconciliation)
+
+	if history != nil {
+		leitner := history.Leitner
+
+		if boxCount == 0 {
+			// Keep the box count the history was created with
+			boxCount = leitner.BoxCount
+		}
+
+		if boxCount < 1 {
+			boxCount = defaultBoxCount
+		}
+
+		previousBoxCount := len(leitner.Boxes)
+
+		if leitner.resize(boxCount) {
+			fmt.Printf("Definitions redistributed from %d to %d boxes\n", previousBoxCount, boxCount)
+		}
+
+		reconciliations[algorithmLeitner] = leitner.reconcile(deck.Definitions)
+		deck.Leitner = leitner
+
+		if history.SM2 != nil {
+			reconciliations[algorithmSM2] = history.SM2.reconcile(deck.Definitions)
+			deck.SM2 = history.SM2
+		}
+
+		if history.FSRS != nil {
+			reconciliations[algorithmFSRS] = history.FSRS.reconcile(deck.Definitions)
+			deck.FSRS = history.FSRS
+		}
+
+		if algorithm == "" {
+			algorithm = history.Algorithm
+		}
+	} else if boxCount > 0 {
+		deck.Leitner.resize(boxCount)
+	}
+
+	if algorithm == "" {
+		algorithm = algorithmLeitner
+	}
 
-		fmt.Printf("\n%s\n\n", aurora.Red("============ WRONG ============"))
-		fmt.Printf("%s:\n%s\n\n", aurora.Blue("Correct answer"), correctAnswer)
+	deck.useAlgorithm(algorithm)
+
+	if algorithm == algorithmFSRS {
+		parameters, err := loadFSRSParameters(fsrsParametersPath(deckPath))
+
+		if err != nil {
+			return nil, fmt.Errorf("cannot load the FSRS parameters file %s", err)
+		}
+
+		if parameters != nil {
+			deck.FSRS.Weights = parameters.Weights
+			fmt.Printf("Using FSRS weights optimized on %s\n", parameters.OptimizedAt.Format("2006-01-02"))
+		}
+	}
+
+	if reconciliation, ok := reconciliations[algorithm]; ok {
+		printReconciliation(reconciliation)
 	}
+
+	return deck, nil
 }
 
-func main() {
-	rand.Seed(time.Now().UnixNano())
+func studyCommand(args []string) error {
 	session := &Session{}
-
-	command := readCommandLine()
+	command := readCommandLine(args)
 
 	if *command.convertFromKV != "" {
-		err := convertKeyValueToDeckFile(*command.convertFromKV)
+		return convertKeyValueToDeckFile(*command.convertFromKV)
+	}
 
-		if err == nil {
-			os.Exit(0)
-		}
+	if *command.algorithm != "" && !isValidAlgorithm(*command.algorithm) {
+		return fmt.Errorf("unknown algorithm '%s'", *command.algorithm)
+	}
 
-		fmt.Println(fmt.Errorf("error: %s", err))
-		os.Exit(1)
+	if *command.accents != "" && !isValidAccentsMode(*command.accents) {
+		return fmt.Errorf("unknown accents mode '%s'", *command.accents)
+	}
+
+	if !isValidMode(*command.mode) {
+		return fmt.Errorf("unknown mode '%s'", *command.mode)
 	}
 
-	data, err := loadFile(*command.deckPath)
+	if *command.choices < minChoices || *command.choices > maxChoices {
+		return fmt.Errorf("invalid number of choices %d, it must be from %d to %d", *command.choices, minChoices, maxChoices)
+	}
+
+	if *command.backups < 0 {
+		return fmt.Errorf("invalid number of backups %d, it can't be negative", *command.backups)
+	}
+
+	if len(command.deckPaths) == 0 {
+		return errors.New("no deck given, use -deck-path")
+	}
+
+	paths, err := expandDeckPaths(command.deckPaths)
 
 	if err != nil {
-		fmt.Printf("File '%s' does not exist\n", *command.deckPath)
-		os.Exit(1)
+		return err
 	}
 
-	// TODO: Load only missing definitions, if the history file is present
+	for _, path := range paths {
+		if len(paths) > 1 {
+			fmt.Println(aurora.Blue(deckName(path)))
+		}
 
-	deck := loadDeck(data)
-	deck.shuffle()
+		deck, err := openDeck(command, path)
 
-	jsonFile, _ := os.Open(fmt.Sprintf("%s.history.json", *command.deckPath))
-	defer jsonFile.Close()
+		if err != nil {
+			return err
+		}
 
-	byteValue, _ := ioutil.ReadAll(jsonFile)
+		if err := backupHistory(historyPath(path), *command.backups); err != nil {
+			return fmt.Errorf("cannot back up the deck history file %s", err)
+		}
 
-	json.Unmarshal([]byte(byteValue), &deck)
+		session.decks = append(session.decks, &StudyDeck{
+			path: path,
+			deck: deck,
+		})
+	}
 
-	deck.Definitions = []Definition{}
+	signals := setupEndOfSessionHandler(session)
 
-	setupEndOfSessionHandler(session, deck, *command.deckPath)
+	// Debug output is printed between the questions, so it needs the line by line interface
+	if *command.tui && !*command.debug && isTerminal(os.Stdin) && isTerminal(os.Stdout) {
+		newFullScreen(session, command, signals).run()
+	}
 
 	input := bufio.NewScanner(os.Stdin)
 
-	leitner := deck.Leitner
+	// Sessions end in endSession, which exits
+	for {
+		session.mutex.Lock()
+		study, question := session.nextQuestion(command)
+		session.mutex.Unlock()
 
-	for true {
-		cont, question, answer := prepareQuestion(command, deck)
+		if question == nil {
+			printNothingDue(session.nextDue())
+			endSession(session)
+		}
+
+		label := "Question"
 
-		if cont {
-			continue
+		if len(session.decks) > 1 {
+			label = fmt.Sprintf("Question (%s)", deckName(study.path))
 		}
 
-		fmt.Printf("%s: \n%s\n\n%s:\n", aurora.Yellow("Question"), question, aurora.Yellow("Answer"))
+		fmt.Printf("%s: \n%s\n\n", aurora.Yellow(label), renderMarkup(formatAlternatives(question.Text)))
 
-		input.Scan()
+		askedAt := now()
+
+		var answer string
+		var match Match
+		var grade Grade
+		var ok bool
+
+		if *command.mode == modeFlip {
+			// The key marking the answer grades it as well
+			grade, ok = flipCard(input, question)
+			match = matchExact
+
+			if grade == gradeAgain {
+				match = matchWrong
+			}
+		} else {
+			answer, match, ok = askAnswer(input, command, study.deck, question)
+		}
+
+		if !ok {
+			// Input closed, e.g. the terminal is gone
+			endSession(session)
+		}
 
-		recordAnswer(input.Text(), answer, session, leitner)
+		entry := ReviewLogEntry{
+			Time:         now(),
+			CardID:       question.Definition.ID,
+			Direction:    question.Direction,
+			Answer:       answer,
+			Result:       match,
+			ResponseTime: now().Sub(askedAt).Milliseconds(),
+		}
+
+		entry.Grade = grade
+
+		if grade == 0 {
+			entry.Grade = readGrade(input, suggestGrade(entry.Result))
+		}
+
+		// Saved after every answer, so no answer is lost however the session ends
+		session.mutex.Lock()
+		logAnswer(entry, session, study)
+		saveDeck(study.deck, study.path)
+		session.mutex.Unlock()
+	}
+}
+
+func main() {
+	rand.Seed(time.Now().UnixNano())
+
+	if err := runCommand(os.Args[1:]); err != nil {
+		fmt.Println(fmt.Errorf("error: %s", err))
+		os.Exit(1)
 	}
 }
@@ -1,26 +1,30 @@
 package main
 
 import (
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 )
 
 func getDeck() *Deck {
+	leitner := initLeitner(3, definitions)
+
+	// Every box is due right after a review, so definitions cycle within a single session
+	leitner.Intervals = []int{0, 0, 0}
+
 	return &Deck{
-		definitions: definitions,
-		leitner:     initLeitner(3, definitions),
+		Definitions: definitions,
+		Leitner:     leitner,
 	}
 }
 
 func getCommand(order string) *CommandLine {
 	debug := false
-	deckPath := ""
 
 	return &CommandLine{
-		debug:    &debug,
-		deckPath: &deckPath,
-		order:    &order,
+		debug: &debug,
+		order: &order,
 	}
 }
 
@@ -32,159 +36,224 @@ func getSession() *Session {
 }
 
 func checkBoxes(t *testing.T, leitner *Leitner, definitions1 []Definition, definitions2 []Definition, definitions3 []Definition) {
-	box1 := leitner.boxes[0]
-	box2 := leitner.boxes[1]
-	box3 := leitner.boxes[2]
+	box1 := leitner.Boxes[0]
+	box2 := leitner.Boxes[1]
+	box3 := leitner.Boxes[2]
 
-	assert.Equal(t, definitions1, box1.definitions)
-	assert.Equal(t, definitions2, box2.definitions)
-	assert.Equal(t, definitions3, box3.definitions)
+	assert.Equal(t, definitions1, box1.Definitions)
+	assert.Equal(t, definitions2, box2.Definitions)
+	assert.Equal(t, definitions3, box3.Definitions)
 }
 
 // Go through the question-answer flow.
 // The goal is the check whether we're asking some questions (the ones we're getting wrong) more often.
 func TestMain__question_answer_flow(t *testing.T) {
 	deck := getDeck()
-	leitner := deck.leitner
+	leitner := deck.Leitner
 
 	command := getCommand("standard")
 	session := getSession()
 
 	stats := make(map[string]int)
 
-	assert.Equal(t, 2, leitner.stage)
-	checkBoxes(t, leitner, []Definition{defToGo, defToBe, defToSee, defToSleep}, []Definition(nil), []Definition(nil))
+	assert.Equal(t, 2, leitner.Stage)
+	checkBoxes(t, leitner, []Definition{defToGo, defToBe, defToSee, defToSleep}, []Definition{}, []Definition{})
 
 	// Stage = 0
 
-	_, q, answer := prepareQuestion(command, deck)
+	q := prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 0, leitner.stage)
-	assert.Equal(t, &defToBe, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{defToGo, defToSee, defToSleep}, []Definition(nil), []Definition(nil))
+	assert.Equal(t, 0, leitner.Stage)
+	assert.Equal(t, &defToBe, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
+	checkBoxes(t, leitner, []Definition{defToGo, defToSee, defToSleep}, []Definition{}, []Definition{})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 0, leitner.stage)
-	assert.Equal(t, &defToGo, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{defToSee, defToSleep}, []Definition(nil), []Definition(nil))
+	assert.Equal(t, 0, leitner.Stage)
+	assert.Equal(t, &defToGo, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
+	checkBoxes(t, leitner, []Definition{defToSee, defToSleep}, []Definition{}, []Definition{})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 0, leitner.stage)
-	assert.Equal(t, &defToSee, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{defToSleep}, []Definition(nil), []Definition(nil))
+	assert.Equal(t, 0, leitner.Stage)
+	assert.Equal(t, &defToSee, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
+	checkBoxes(t, leitner, []Definition{defToSleep}, []Definition{}, []Definition{})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 0, leitner.stage)
-	assert.Equal(t, &defToSleep, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{}, []Definition(nil), []Definition(nil))
+	assert.Equal(t, 0, leitner.Stage)
+	assert.Equal(t, &defToSleep, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
+	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{})
 
 	// Stage = 1
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 1, leitner.stage)
-	assert.Equal(t, &defToBe, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{}, []Definition{defToGo, defToSee, defToSleep}, []Definition(nil))
+	assert.Equal(t, 1, leitner.Stage)
+	assert.Equal(t, &defToBe, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
+	checkBoxes(t, leitner, []Definition{}, []Definition{defToGo, defToSee, defToSleep}, []Definition{})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 1, leitner.stage)
-	assert.Equal(t, &defToGo, leitner.currentDefinition)
-	recordAnswer("wrong", answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{}, []Definition{defToSee, defToSleep}, []Definition(nil))
+	assert.Equal(t, 1, leitner.Stage)
+	assert.Equal(t, &defToGo, leitner.CurrentDefinition)
+	recordAnswer(gradeAgain, session, leitner)
+	checkBoxes(t, leitner, []Definition{}, []Definition{defToSee, defToSleep}, []Definition{})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 1, leitner.stage)
-	assert.Equal(t, &defToSee, leitner.currentDefinition)
-	recordAnswer("wrong", answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{}, []Definition{defToSleep}, []Definition(nil))
+	assert.Equal(t, 1, leitner.Stage)
+	assert.Equal(t, &defToSee, leitner.CurrentDefinition)
+	recordAnswer(gradeAgain, session, leitner)
+	checkBoxes(t, leitner, []Definition{}, []Definition{defToSleep}, []Definition{})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 1, leitner.stage)
-	assert.Equal(t, &defToSleep, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
-	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition(nil))
+	assert.Equal(t, 1, leitner.Stage)
+	assert.Equal(t, &defToSleep, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
+	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{})
 
 	// Stage = 2
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 2, leitner.stage)
-	assert.Equal(t, &defToGo, leitner.currentDefinition)
-	recordAnswer("wrong", answer, session, leitner)
+	assert.Equal(t, 2, leitner.Stage)
+	assert.Equal(t, &defToGo, leitner.CurrentDefinition)
+	recordAnswer(gradeAgain, session, leitner)
 	checkBoxes(t, leitner, []Definition{defToSee}, []Definition{}, []Definition{defToBe, defToSleep})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 2, leitner.stage)
-	assert.Equal(t, &defToSee, leitner.currentDefinition)
-	recordAnswer("wrong", answer, session, leitner)
+	assert.Equal(t, 2, leitner.Stage)
+	assert.Equal(t, &defToSee, leitner.CurrentDefinition)
+	recordAnswer(gradeAgain, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{defToBe, defToSleep})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 2, leitner.stage)
-	assert.Equal(t, &defToBe, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
+	assert.Equal(t, 2, leitner.Stage)
+	assert.Equal(t, &defToBe, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{defToSleep})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 2, leitner.stage)
-	assert.Equal(t, &defToSleep, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
+	assert.Equal(t, 2, leitner.Stage)
+	assert.Equal(t, &defToSleep, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{})
 
 	// Stage = 0
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 0, leitner.stage)
-	assert.Equal(t, &defToGo, leitner.currentDefinition)
-	recordAnswer("wrong", answer, session, leitner)
+	assert.Equal(t, 0, leitner.Stage)
+	assert.Equal(t, &defToGo, leitner.CurrentDefinition)
+	recordAnswer(gradeAgain, session, leitner)
 	checkBoxes(t, leitner, []Definition{defToSee}, []Definition{}, []Definition{defToBe, defToSleep})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 0, leitner.stage)
-	assert.Equal(t, &defToSee, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
+	assert.Equal(t, 0, leitner.Stage)
+	assert.Equal(t, &defToSee, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{defToBe, defToSleep})
 
 	// Stage = 1
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 1, leitner.stage)
-	assert.Equal(t, &defToGo, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
+	assert.Equal(t, 1, leitner.Stage)
+	assert.Equal(t, &defToGo, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{defToSee}, []Definition{defToBe, defToSleep})
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 1, leitner.stage)
-	assert.Equal(t, &defToSee, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
+	assert.Equal(t, 1, leitner.Stage)
+	assert.Equal(t, &defToSee, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{defToBe, defToSleep})
 
 	// Stage = 2
 
-	_, q, answer = prepareQuestion(command, deck)
+	q = prepareQuestion(command, deck).Text
 	stats[q]++
-	assert.Equal(t, 2, leitner.stage)
-	assert.Equal(t, &defToGo, leitner.currentDefinition)
-	recordAnswer(answer, answer, session, leitner)
+	assert.Equal(t, 2, leitner.Stage)
+	assert.Equal(t, &defToGo, leitner.CurrentDefinition)
+	recordAnswer(gradeGood, session, leitner)
 	checkBoxes(t, leitner, []Definition{}, []Definition{}, []Definition{defToBe, defToSee, defToSleep})
 
 	assert.Equal(t, map[string]int{"andare": 6, "dormire": 3, "essere": 3, "vedere": 5}, stats)
 }
+
+func TestLogAnswer(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	deckPath := filepath.Join(dir, "test.deck")
+	deck := getDeck()
+	deck.Algorithm = algorithmLeitner
+	session := getSession()
+
+	study := &StudyDeck{path: deckPath, deck: deck}
+
+	question := prepareQuestion(getCommand("reversed"), deck)
+	assert.Equal(t, "to be", question.Text)
+	assert.Equal(t, directionReversed, question.Direction)
+
+	logAnswer(ReviewLogEntry{
+		CardID:    question.Definition.ID,
+		Direction: question.Direction,
+		Answer:    "essere",
+		Result:    matchExact,
+		Grade:     gradeGood,
+	}, session, study)
+
+	entries, err := loadReviewLog(reviewLogPath(deckPath))
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "essere", entries[0].CardID)
+	assert.Equal(t, algorithmLeitner, entries[0].Algorithm)
+	assert.Equal(t, 0, entries[0].BoxBefore)
+	assert.Equal(t, 1, entries[0].BoxAfter)
+	assert.Equal(t, 1, session.correctAnswers)
+	assert.Equal(t, 1, study.correctAnswers)
+}
+
+func TestGetQuestionAnswer_bidirectional_ignores_order(t *testing.T) {
+	reversed := Definition{ID: "andare-reversed", From: "to go", To: "andare", Direction: directionReversed}
+
+	for _, order := range []string{"standard", "reversed", "random"} {
+		question, answer, direction := getQuestionAnswer(getCommand(order), &reversed)
+
+		assert.Equal(t, "to go", question)
+		assert.Equal(t, "andare", answer)
+		assert.Equal(t, directionReversed, direction)
+	}
+}
+
+func TestBidirectional_directions_have_own_boxes(t *testing.T) {
+	deck, _ := loadDeck("[ @id go @bidirectional (andare) (to go) ]")
+	leitner := deck.Leitner
+	leitner.Intervals = []int{0, 0, 0}
+
+	// Definitions in a box are asked by answer, so the reversed one comes first
+	def := leitner.next()
+	assert.Equal(t, "go-reversed", def.ID)
+	leitner.record(gradeAgain)
+
+	def = leitner.next()
+	assert.Equal(t, "go", def.ID)
+	leitner.record(gradeGood)
+
+	assert.Equal(t, 1, leitner.level("go"))
+	assert.Equal(t, 0, leitner.level("go-reversed"))
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/logrusorgru/aurora"
+)
+
+type markupStyle int
+
+const (
+	stylePlain markupStyle = iota
+	// **text**
+	styleBold
+	// *text* or _text_
+	styleItalic
+	// ==text==, e.g. the part of a sentence being learned
+	styleHighlight
+)
+
+type markupSpan struct {
+	text  string
+	style markupStyle
+}
+
+var markupDelimiters = []struct {
+	delimiter string
+	style     markupStyle
+}{
+	{"**", styleBold},
+	{"==", styleHighlight},
+	{"*", styleItalic},
+	{"_", styleItalic},
+}
+
+// Split text into styled spans. A delimiter without a closing pair, or in the middle of a word
+// (e.g. snake_case), is plain text.
+func parseMarkup(text string) []markupSpan {
+	spans := []markupSpan{}
+	runes := []rune(text)
+
+	var plain strings.Builder
+
+	for i := 0; i < len(runes); i++ {
+		span, end := readStyledSpan(runes, i)
+
+		if end == 0 {
+			plain.WriteRune(runes[i])
+			continue
+		}
+
+		if plain.Len() > 0 {
+			spans = append(spans, markupSpan{plain.String(), stylePlain})
+			plain.Reset()
+		}
+
+		spans = append(spans, span)
+		i = end - 1
+	}
+
+	if plain.Len() > 0 {
+		spans = append(spans, markupSpan{plain.String(), stylePlain})
+	}
+
+	return spans
+}
+
+// A styled span starting at i and the index right after it, 0 if there's none.
+func readStyledSpan(runes []rune, i int) (markupSpan, int) {
+	if i > 0 && isWordRune(runes[i-1]) {
+		return markupSpan{}, 0
+	}
+
+	for _, markup := range markupDelimiters {
+		delimiter := []rune(markup.delimiter)
+
+		if !hasRunesAt(runes, i, delimiter) {
+			continue
+		}
+
+		start := i + len(delimiter)
+
+		if start >= len(runes) || unicode.IsSpace(runes[start]) {
+			return markupSpan{}, 0
+		}
+
+		for end := start + 1; end+len(delimiter) <= len(runes); end++ {
+			if !hasRunesAt(runes, end, delimiter) || unicode.IsSpace(runes[end-1]) {
+				continue
+			}
+
+			if end+len(delimiter) < len(runes) && isWordRune(runes[end+len(delimiter)]) {
+				continue
+			}
+
+			return markupSpan{string(runes[start:end]), markup.style}, end + len(delimiter)
+		}
+
+		return markupSpan{}, 0
+	}
+
+	return markupSpan{}, 0
+}
+
+func hasRunesAt(runes []rune, i int, prefix []rune) bool {
+	if i+len(prefix) > len(runes) {
+		return false
+	}
+
+	for j, char := range prefix {
+		if runes[i+j] != char {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isWordRune(char rune) bool {
+	return unicode.IsLetter(char) || unicode.IsDigit(char)
+}
+
+// Text with the markup rendered as terminal colors.
+func renderMarkup(text string) string {
+	var rendered strings.Builder
+
+	for _, span := range parseMarkup(text) {
+		switch span.style {
+		case styleBold:
+			rendered.WriteString(aurora.Bold(span.text).String())
+		case styleItalic:
+			rendered.WriteString(aurora.Italic(span.text).String())
+		case styleHighlight:
+			rendered.WriteString(aurora.Cyan(span.text).Bold().Underline().String())
+		default:
+			rendered.WriteString(span.text)
+		}
+	}
+
+	return rendered.String()
+}
+
+// Text without the markup, as it's typed in answers.
+func plainText(text string) string {
+	var plain strings.Builder
+
+	for _, span := range parseMarkup(text) {
+		plain.WriteString(span.text)
+	}
+
+	return plain.String()
+}
+
+// Side of a definition on a single line, for lists and tables.
+func formatOneLine(side string) string {
+	return strings.Join(strings.Fields(plainText(formatAlternatives(side))), " ")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/logrusorgru/aurora"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMarkup(t *testing.T) {
+	assert.Equal(t, []markupSpan{
+		{"io ", stylePlain},
+		{"vado", styleBold},
+		{", tu ", stylePlain},
+		{"vai", styleItalic},
+		{" ", stylePlain},
+		{"lui va", styleHighlight},
+		{" ", stylePlain},
+		{"noi", styleItalic},
+	}, parseMarkup("io **vado**, tu *vai* ==lui va== _noi_"))
+}
+
+func TestParseMarkup_plain_delimiters(t *testing.T) {
+	for _, text := range []string{"snake_case_name", "2 * 3 * 4", "a ** b", "*unclosed", "**", "a == b == c", "x*y*"} {
+		assert.Equal(t, []markupSpan{{text, stylePlain}}, parseMarkup(text), text)
+	}
+
+	assert.Empty(t, parseMarkup(""))
+}
+
+func TestPlainText(t *testing.T) {
+	assert.Equal(t, "io vado\ntu vai", plainText("io **vado**\ntu _vai_"))
+}
+
+func TestRenderMarkup(t *testing.T) {
+	assert.Equal(t, "a "+aurora.Bold("b").String(), renderMarkup("a **b**"))
+	assert.Equal(t, "a b", renderMarkup("a b"))
+}
+
+func TestFormatOneLine(t *testing.T) {
+	assert.Equal(t, "io vado tu vai / andare", formatOneLine("io **vado**\n    tu vai | andare"))
+}
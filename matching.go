@@ -0,0 +1,258 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// Answers without diacritics are accepted, with a warning
+	accentsLenient = "lenient"
+	// Diacritics must match
+	accentsStrict = "strict"
+)
+
+type Match int
+
+const (
+	matchWrong Match = iota
+	// Accepted, but with typos
+	matchAlmost
+	// Accepted, but accents are missing or different
+	matchMissingAccents
+	matchExact
+)
+
+// Not an answer: the last answer to the card was undone, it's left out of statistics
+const matchUndone Match = -1
+
+var matchNames = map[Match]string{
+	matchUndone:         "undone",
+	matchWrong:          "wrong",
+	matchAlmost:         "almost",
+	matchMissingAccents: "missing-accents",
+	matchExact:          "exact",
+}
+
+func (match Match) String() string {
+	return matchNames[match]
+}
+
+// Matches are stored by name in review logs.
+func (match Match) MarshalText() ([]byte, error) {
+	name, ok := matchNames[match]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown match %d", match)
+	}
+
+	return []byte(name), nil
+}
+
+func (match *Match) UnmarshalText(text []byte) error {
+	for candidate, name := range matchNames {
+		if string(text) == name {
+			*match = candidate
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown match '%s'", text)
+}
+
+// How strictly answers are compared, set per deck.
+type Matcher struct {
+	// Highest accepted Levenshtein distance from the correct answer, 0 accepts exact answers only
+	MaxTypos int
+
+	StrictAccents bool
+}
+
+func isValidAccentsMode(mode string) bool {
+	return mode == accentsLenient || mode == accentsStrict
+}
+
+type diffOperation int
+
+const (
+	diffEqual diffOperation = iota
+	diffSubstitute
+	// Character typed in the answer but not present in the correct answer
+	diffInsert
+	// Character of the correct answer missing from the answer
+	diffDelete
+)
+
+type diffStep struct {
+	operation diffOperation
+	answer    rune
+	correct   rune
+}
+
+// Trim the answer and collapse runs of whitespace to single spaces.
+// Accented characters are composed, so "è" typed as "e" followed by a combining accent
+// is the same as a single "è".
+func normalizeSpaces(answer string) string {
+	return norm.NFC.String(strings.Join(strings.Fields(answer), " "))
+}
+
+// Remove diacritics by decomposing characters (NFD) and dropping the combining marks, e.g. "è" becomes "e".
+func stripAccents(text string) string {
+	var stripped strings.Builder
+
+	for _, char := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, char) {
+			stripped.WriteRune(char)
+		}
+	}
+
+	return norm.NFC.String(stripped.String())
+}
+
+func equalRunes(a rune, b rune) bool {
+	return unicode.ToLower(a) == unicode.ToLower(b)
+}
+
+// Align the answer with the correct answer with the fewest edits (Levenshtein distance).
+// Letter case is ignored.
+func alignAnswers(answer []rune, correct []rune) []diffStep {
+	// distances[i][j] - edits needed to turn answer[:i] into correct[:j]
+	distances := make([][]int, len(answer)+1)
+
+	for i := range distances {
+		distances[i] = make([]int, len(correct)+1)
+		distances[i][0] = i
+	}
+
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+
+	for i := 1; i <= len(answer); i++ {
+		for j := 1; j <= len(correct); j++ {
+			cost := 1
+
+			if equalRunes(answer[i-1], correct[j-1]) {
+				cost = 0
+			}
+
+			distances[i][j] = minInt(
+				distances[i-1][j-1]+cost,
+				minInt(distances[i-1][j]+1, distances[i][j-1]+1),
+			)
+		}
+	}
+
+	steps := []diffStep{}
+	i, j := len(answer), len(correct)
+
+	for i > 0 || j > 0 {
+		switch {
+		case i > 0 && j > 0 && equalRunes(answer[i-1], correct[j-1]) && distances[i][j] == distances[i-1][j-1]:
+			steps = append(steps, diffStep{diffEqual, answer[i-1], correct[j-1]})
+			i--
+			j--
+		case i > 0 && j > 0 && distances[i][j] == distances[i-1][j-1]+1:
+			steps = append(steps, diffStep{diffSubstitute, answer[i-1], correct[j-1]})
+			i--
+			j--
+		case i > 0 && distances[i][j] == distances[i-1][j]+1:
+			steps = append(steps, diffStep{diffInsert, answer[i-1], 0})
+			i--
+		default:
+			steps = append(steps, diffStep{diffDelete, 0, correct[j-1]})
+			j--
+		}
+	}
+
+	for left, right := 0, len(steps)-1; left < right; left, right = left+1, right-1 {
+		steps[left], steps[right] = steps[right], steps[left]
+	}
+
+	return steps
+}
+
+func editDistance(steps []diffStep) int {
+	distance := 0
+
+	for _, step := range steps {
+		if step.operation != diffEqual {
+			distance++
+		}
+	}
+
+	return distance
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// Compare answers ignoring letter case and extra whitespace.
+// Unless accents are strict, answers differing from the correct one only in diacritics are accepted.
+// Answers with a few typos are accepted as almost correct, as long as
+// the typos don't make up half of the correct answer.
+func (matcher Matcher) match(userAnswer string, correctAnswer string) Match {
+	answer := normalizeSpaces(userAnswer)
+	correct := normalizeSpaces(correctAnswer)
+
+	distance := editDistance(alignAnswers([]rune(answer), []rune(correct)))
+
+	if distance == 0 {
+		return matchExact
+	}
+
+	if !matcher.StrictAccents {
+		answer = stripAccents(answer)
+		correct = stripAccents(correct)
+
+		distance = editDistance(alignAnswers([]rune(answer), []rune(correct)))
+
+		if distance == 0 {
+			return matchMissingAccents
+		}
+	}
+
+	if distance <= matcher.MaxTypos && distance*2 < len([]rune(correct)) {
+		return matchAlmost
+	}
+
+	return matchWrong
+}
+
+// Compare the answer with every accepted alternative.
+// Returns the best match and the alternative it was made with.
+func (matcher Matcher) matchAlternatives(userAnswer string, correctAnswer string) (Match, string) {
+	best := matchWrong
+	bestAlternative := correctAnswer
+
+	for _, alternative := range getAlternatives(correctAnswer) {
+		match := matcher.match(userAnswer, alternative)
+
+		if match > best {
+			best = match
+			bestAlternative = alternative
+		}
+	}
+
+	return best, bestAlternative
+}
+
+// Grade suggested for the answer, before it's graded by hand.
+func suggestGrade(match Match) Grade {
+	switch match {
+	case matchExact, matchMissingAccents:
+		return gradeGood
+	case matchAlmost:
+		return gradeHard
+	}
+
+	return gradeAgain
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeSpaces(t *testing.T) {
+	assert.Equal(t, "to go home", normalizeSpaces("  to   go\thome "))
+}
+
+func TestAlignAnswers(t *testing.T) {
+	steps := alignAnswers([]rune("to gp"), []rune("to go"))
+
+	assert.Equal(t, []diffStep{
+		{diffEqual, 't', 't'},
+		{diffEqual, 'o', 'o'},
+		{diffEqual, ' ', ' '},
+		{diffEqual, 'g', 'g'},
+		{diffSubstitute, 'p', 'o'},
+	}, steps)
+	assert.Equal(t, 1, editDistance(steps))
+}
+
+func TestAlignAnswers_missing_and_extra_characters(t *testing.T) {
+	steps := alignAnswers([]rune("slep"), []rune("sleep"))
+
+	assert.Equal(t, 1, editDistance(steps))
+	assert.Equal(t, 5, len(steps))
+	assert.Contains(t, steps, diffStep{diffDelete, 0, 'e'})
+
+	steps = alignAnswers([]rune("xsee"), []rune("see"))
+
+	assert.Equal(t, 1, editDistance(steps))
+	assert.Equal(t, diffStep{diffInsert, 'x', 0}, steps[0])
+}
+
+func TestMatch_exact(t *testing.T) {
+	matcher := Matcher{}
+
+	assert.Equal(t, matchExact, matcher.match("to go", "to go"))
+	assert.Equal(t, matchExact, matcher.match(" To  Go ", "to go"))
+	assert.Equal(t, matchWrong, matcher.match("to gp", "to go"))
+}
+
+func TestMatch_typos(t *testing.T) {
+	matcher := Matcher{MaxTypos: 1}
+
+	assert.Equal(t, matchAlmost, matcher.match("to gp", "to go"))
+	assert.Equal(t, matchAlmost, matcher.match("to slep", "to sleep"))
+	assert.Equal(t, matchWrong, matcher.match("to gpp", "to go"))
+
+	// A single typo is half of the answer
+	assert.Equal(t, matchWrong, matcher.match("il", "io"))
+}
+
+func TestSuggestGrade(t *testing.T) {
+	assert.Equal(t, gradeGood, suggestGrade(matchExact))
+	assert.Equal(t, gradeHard, suggestGrade(matchAlmost))
+	assert.Equal(t, gradeAgain, suggestGrade(matchWrong))
+}
+
+func TestStripAccents(t *testing.T) {
+	assert.Equal(t, "perche citta", stripAccents("perché città"))
+	assert.Equal(t, "e", stripAccents("è"))
+}
+
+func TestMatch_accents(t *testing.T) {
+	matcher := Matcher{}
+
+	assert.Equal(t, matchExact, matcher.match("città", "città"))
+	// Decomposed "a" followed by a combining grave accent
+	assert.Equal(t, matchExact, matcher.match("citta\u0300", "città"))
+	assert.Equal(t, matchMissingAccents, matcher.match("citta", "città"))
+	assert.Equal(t, matchMissingAccents, matcher.match("perchè", "perché"))
+	assert.Equal(t, matchWrong, matcher.match("cita", "città"))
+}
+
+func TestMatch_strict_accents(t *testing.T) {
+	matcher := Matcher{StrictAccents: true}
+
+	assert.Equal(t, matchExact, matcher.match("città", "città"))
+	assert.Equal(t, matchWrong, matcher.match("citta", "città"))
+
+	matcher.MaxTypos = 1
+
+	assert.Equal(t, matchAlmost, matcher.match("citta", "città"))
+}
+
+func TestMatch_typos_and_missing_accents(t *testing.T) {
+	matcher := Matcher{MaxTypos: 1}
+
+	assert.Equal(t, matchAlmost, matcher.match("cita", "città"))
+}
+
+func TestMatchAlternatives(t *testing.T) {
+	matcher := Matcher{MaxTypos: 1}
+
+	match, alternative := matcher.matchAlternatives("to notice", "to realize | to notice")
+
+	assert.Equal(t, matchExact, match)
+	assert.Equal(t, "to notice", alternative)
+
+	match, alternative = matcher.matchAlternatives("to realise", "to realize | to notice")
+
+	assert.Equal(t, matchAlmost, match)
+	assert.Equal(t, "to realize", alternative)
+
+	match, _ = matcher.matchAlternatives("to see", "to realize | to notice")
+
+	assert.Equal(t, matchWrong, match)
+}
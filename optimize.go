@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	fsrsMinPredictions = 20
+	fsrsLearningRate   = 0.005
+)
+
+// Ranges the weights are kept in while fitting, so the model stays meaningful.
+var fsrsWeightBounds = [][2]float64{
+	{0.1, 100}, {0.1, 100}, {0.1, 100}, {0.1, 100},
+	{1, 10}, {0.1, 5}, {0.1, 5}, {0, 0.75},
+	{0, 4}, {0, 0.8}, {0.01, 3},
+	{0.5, 5}, {0.01, 0.2}, {0.01, 0.9}, {0.01, 2},
+	{0, 1}, {1, 6},
+}
+
+// FSRS weights fitted to the deck's review history by the optimize command.
+type FSRSParameters struct {
+	Weights     []float64 `json:"weights"`
+	Reviews     int       `json:"reviews"`
+	Loss        float64   `json:"loss"`
+	OptimizedAt time.Time `json:"optimized_at"`
+}
+
+func fsrsParametersPath(deckPath string) string {
+	return fmt.Sprintf("%s.fsrs.json", deckPath)
+}
+
+// Returns nil if the deck hasn't been optimized yet.
+func loadFSRSParameters(path string) (*FSRSParameters, error) {
+	data, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var parameters FSRSParameters
+
+	if err := json.Unmarshal(data, &parameters); err != nil {
+		return nil, err
+	}
+
+	if len(parameters.Weights) != len(fsrsDefaultWeights) {
+		return nil, fmt.Errorf("expected %d weights, got %d", len(fsrsDefaultWeights), len(parameters.Weights))
+	}
+
+	return &parameters, nil
+}
+
+func saveFSRSParameters(path string, parameters *FSRSParameters) error {
+	data, err := json.MarshalIndent(parameters, "", " ")
+
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data, 0644)
+}
+
+// Review histories of all cards, every one sorted from the oldest review. Relearning steps are left out,
+// they didn't change the schedule.
+func fsrsReviewHistories(fsrs *FSRS) [][]Review {
+	histories := [][]Review{}
+
+	for _, card := range fsrs.sortedCards() {
+		reviews := card.scheduledReviews()
+
+		if len(reviews) == 0 {
+			continue
+		}
+
+		sort.Slice(reviews, func(i, j int) bool {
+			return reviews[i].Time.Before(reviews[j].Time)
+		})
+
+		histories = append(histories, reviews)
+	}
+
+	return histories
+}
+
+// Replay the reviews with the given weights and compare predicted chance of recall with what really happened.
+// Returns the mean log loss and the number of predictions it's based on.
+func fsrsLoss(w []float64, histories [][]Review) (float64, int) {
+	loss := 0.0
+	predictions := 0
+
+	for _, reviews := range histories {
+		stability, difficulty := fsrsStep(w, true, 0, 0, 0, reviews[0].Grade)
+
+		for i := 1; i < len(reviews); i++ {
+			elapsed := daysBetween(reviews[i-1].Time, reviews[i].Time)
+			rating := reviews[i].Grade
+
+			retrievability := clamp(fsrsRetrievability(elapsed, stability), 1e-6, 1-1e-6)
+
+			if rating == gradeAgain {
+				loss -= math.Log(1 - retrievability)
+			} else {
+				loss -= math.Log(retrievability)
+			}
+
+			predictions++
+
+			stability, difficulty = fsrsStep(w, false, stability, difficulty, elapsed, rating)
+			stability = math.Max(stability, 0.01)
+		}
+	}
+
+	if predictions == 0 {
+		return 0, 0
+	}
+
+	return loss / float64(predictions), predictions
+}
+
+// Fit the weights with Adam, using numerical gradients of the log loss.
+func fitFSRS(histories [][]Review, iterations int) []float64 {
+	w := append([]float64(nil), fsrsDefaultWeights...)
+
+	const beta1, beta2, epsilon = 0.9, 0.999, 1e-8
+
+	m := make([]float64, len(w))
+	v := make([]float64, len(w))
+	gradient := make([]float64, len(w))
+
+	for iteration := 1; iteration <= iterations; iteration++ {
+		for i := range w {
+			h := 1e-4 * math.Max(1, math.Abs(w[i]))
+			original := w[i]
+
+			w[i] = original + h
+			lossUp, _ := fsrsLoss(w, histories)
+			w[i] = original - h
+			lossDown, _ := fsrsLoss(w, histories)
+			w[i] = original
+
+			gradient[i] = (lossUp - lossDown) / (2 * h)
+		}
+
+		for i := range w {
+			m[i] = beta1*m[i] + (1-beta1)*gradient[i]
+			v[i] = beta2*v[i] + (1-beta2)*gradient[i]*gradient[i]
+
+			mHat := m[i] / (1 - math.Pow(beta1, float64(iteration)))
+			vHat := v[i] / (1 - math.Pow(beta2, float64(iteration)))
+
+			bounds := fsrsWeightBounds[i]
+			step := fsrsLearningRate * (bounds[1] - bounds[0])
+
+			w[i] = clamp(w[i]-step*mHat/(math.Sqrt(vHat)+epsilon), bounds[0], bounds[1])
+		}
+	}
+
+	return w
+}
+
+// Fit FSRS weights to the review history of a deck and store them next to its history file.
+func optimizeCommand(args []string) error {
+	flags := newFlagSet("optimize", "-deck-path <deck> [-iterations <n>]")
+	deckPath := flags.String("deck-path", "", "Path to deck file")
+	iterations := flags.Int("iterations", 200, "Number of optimization steps")
+
+	flags.Parse(args)
+
+	history, err := loadHistory(historyPath(*deckPath))
+
+	if err != nil {
+		return fmt.Errorf("cannot load the deck history file %s", err)
+	}
+
+	if history == nil || history.FSRS == nil {
+		return errors.New("no FSRS reviews found, study the deck with -algorithm fsrs first")
+	}
+
+	histories := fsrsReviewHistories(history.FSRS)
+	defaultLoss, predictions := fsrsLoss(fsrsDefaultWeights, histories)
+
+	if predictions < fsrsMinPredictions {
+		return fmt.Errorf("not enough reviews to optimize (%d, need at least %d)", predictions, fsrsMinPredictions)
+	}
+
+	weights := fitFSRS(histories, *iterations)
+	loss, _ := fsrsLoss(weights, histories)
+
+	fmt.Printf("Reviews: %d\n", predictions)
+	fmt.Printf("Log loss: %0.4f (default weights: %0.4f)\n", loss, defaultLoss)
+
+	return saveFSRSParameters(fsrsParametersPath(*deckPath), &FSRSParameters{
+		Weights:     weights,
+		Reviews:     predictions,
+		Loss:        loss,
+		OptimizedAt: now(),
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Simulate reviews of cards remembered according to the given weights.
+func simulateReviews(w []float64, cards int, reviews int) [][]Review {
+	random := rand.New(rand.NewSource(1))
+	start := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	histories := [][]Review{}
+
+	for i := 0; i < cards; i++ {
+		history := []Review{{Time: start, Grade: gradeGood}}
+		stability, difficulty := fsrsStep(w, true, 0, 0, 0, gradeGood)
+		reviewTime := start
+
+		for j := 1; j < reviews; j++ {
+			elapsed := float64(1 + random.Intn(2*fsrsInterval(stability)))
+			reviewTime = reviewTime.AddDate(0, 0, int(elapsed))
+
+			rating := gradeGood
+
+			if random.Float64() > fsrsRetrievability(elapsed, stability) {
+				rating = gradeAgain
+			}
+
+			history = append(history, Review{Time: reviewTime, Grade: rating})
+			stability, difficulty = fsrsStep(w, false, stability, difficulty, elapsed, rating)
+		}
+
+		histories = append(histories, history)
+	}
+
+	return histories
+}
+
+func TestFSRSLoss_no_reviews(t *testing.T) {
+	loss, predictions := fsrsLoss(fsrsDefaultWeights, [][]Review{})
+
+	assert.Equal(t, 0.0, loss)
+	assert.Equal(t, 0, predictions)
+}
+
+func TestFitFSRS_improves_loss(t *testing.T) {
+	// Someone who forgets a lot faster than the default model expects
+	w := append([]float64(nil), fsrsDefaultWeights...)
+	w[2] = 0.8
+	w[8] = 0.8
+
+	histories := simulateReviews(w, 40, 6)
+
+	defaultLoss, predictions := fsrsLoss(fsrsDefaultWeights, histories)
+	fitted := fitFSRS(histories, 50)
+	loss, _ := fsrsLoss(fitted, histories)
+
+	assert.Equal(t, 200, predictions)
+	assert.True(t, loss < defaultLoss)
+
+	for i, bounds := range fsrsWeightBounds {
+		assert.True(t, fitted[i] >= bounds[0] && fitted[i] <= bounds[1])
+	}
+}
+
+func TestReviewHistories_sorted_and_skips_new_cards_and_relearning(t *testing.T) {
+	fsrs := initFSRS([]Definition{defToGo, defToBe})
+	day := time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	fsrs.Cards["andare"].Reviews = []Review{
+		{Time: day.AddDate(0, 0, 3), Grade: gradeGood},
+		{Time: day, Grade: gradeAgain},
+		{Time: day, Grade: gradeGood, Relearning: true},
+	}
+
+	histories := fsrsReviewHistories(fsrs)
+
+	assert.Equal(t, [][]Review{{
+		{Time: day, Grade: gradeAgain},
+		{Time: day.AddDate(0, 0, 3), Grade: gradeGood},
+	}}, histories)
+}
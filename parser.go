@@ -0,0 +1,399 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Position in a deck file, line and column both counted from 1.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// Characters that are taken literally after a backslash, e.g. "to go \(informal\)".
+const escapedCharacters = "()[]|\\"
+
+type ParseErrorKind int
+
+const (
+	// A bracket or parenthesis without its pair
+	errorUnbalanced ParseErrorKind = iota + 1
+	// A card without exactly two blocks (or one with cloze gaps), or a block outside of a card
+	errorMalformedCard
+	errorEmptySide
+	errorDuplicateCard
+	errorInvalidDirective
+)
+
+type ParseError struct {
+	Kind ParseErrorKind
+	Position
+	Message string
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", err.Line, err.Column, err.Message)
+}
+
+type parsedBlock struct {
+	Position
+	Text string
+}
+
+type parsedCard struct {
+	Position
+	Blocks []parsedBlock
+
+	// Text inside the brackets but outside of the blocks, e.g. "@id verb-12"
+	Directives string
+
+	// Where the closing bracket is
+	End Position
+}
+
+type parsedDeck struct {
+	// Text outside of the cards, e.g. "@boxes 5"
+	Directives string
+
+	// Where each of the header directives starts
+	DirectivePositions map[string]Position
+
+	Cards []parsedCard
+}
+
+// Split a deck file into cards and their blocks, reporting every problem found on the way.
+// Only cards with a question and an answer, or a cloze sentence, are returned. Lines starting with # are comments.
+// A backslash makes the next bracket, parenthesis or backslash part of the text.
+func parseDeck(data string) (*parsedDeck, []*ParseError) {
+	deck := &parsedDeck{
+		DirectivePositions: make(map[string]Position),
+	}
+	errs := []*ParseError{}
+
+	report := func(kind ParseErrorKind, position Position, format string, args ...interface{}) {
+		errs = append(errs, &ParseError{
+			Kind:     kind,
+			Position: position,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	var header, directives, text strings.Builder
+	var card *parsedCard
+	var block *parsedBlock
+
+	// Parentheses opened inside the current block, they're part of its text
+	depth := 0
+
+	runes := []rune(data)
+	line, column := 1, 0
+
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		column++
+
+		if char == '\n' {
+			line++
+			column = 0
+		}
+
+		if column == 1 && char == '#' {
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+
+			continue
+		}
+
+		position := Position{line, column}
+
+		if char == '\\' && i+1 < len(runes) && strings.ContainsRune(escapedCharacters, runes[i+1]) {
+			i++
+			column++
+
+			switch {
+			case block != nil && runes[i] == alternativesSeparator:
+				// Kept escaped, it's only taken literally once alternatives are split
+				text.WriteString(escapeAlternative(string(runes[i])))
+			case block != nil:
+				text.WriteRune(runes[i])
+			case card != nil:
+				directives.WriteRune(runes[i])
+			default:
+				header.WriteRune(runes[i])
+			}
+
+			continue
+		}
+
+		if block != nil {
+			if char == ')' && depth == 0 {
+				block.Text = dedent(text.String())
+
+				if block.Text == "" {
+					report(errorEmptySide, block.Position, "empty block")
+				} else if len(getAlternatives(block.Text)) == 0 {
+					report(errorEmptySide, block.Position, "block has nothing but separators of alternatives")
+				}
+
+				if card != nil {
+					card.Blocks = append(card.Blocks, *block)
+				}
+
+				block = nil
+
+				continue
+			}
+
+			if char == '(' {
+				depth++
+			} else if char == ')' {
+				depth--
+			}
+
+			text.WriteRune(char)
+
+			continue
+		}
+
+		switch char {
+		case '(':
+			if card == nil {
+				report(errorMalformedCard, position, "block outside of a card, cards are enclosed in [ ]")
+			}
+
+			block = &parsedBlock{Position: position}
+			depth = 0
+			text.Reset()
+		case ')':
+			report(errorUnbalanced, position, "')' without a matching '('")
+		case '[':
+			if card != nil {
+				report(errorUnbalanced, card.Position, "'[' is never closed")
+			}
+
+			card = &parsedCard{Position: position}
+			directives.Reset()
+		case ']':
+			if card == nil {
+				report(errorUnbalanced, position, "']' without a matching '['")
+				continue
+			}
+
+			card.Directives = strings.TrimSpace(directives.String())
+			card.End = position
+
+			if len(card.Blocks) == 2 {
+				deck.Cards = append(deck.Cards, *card)
+			} else if len(card.Blocks) == 1 && hasCloze(card.Blocks[0].Text) {
+				for _, gap := range emptyGaps(card.Blocks[0].Text) {
+					report(errorEmptySide, card.Blocks[0].Position, "gap %s has nothing to answer", gap)
+				}
+
+				deck.Cards = append(deck.Cards, *card)
+			} else if len(card.Blocks) == 1 {
+				report(errorMalformedCard, card.Position, "a card needs a question and an answer, or a sentence with gaps such as {{c1::text}}")
+			} else {
+				report(errorMalformedCard, card.Position, "a card needs a question and an answer, found %d blocks", len(card.Blocks))
+			}
+
+			card = nil
+		default:
+			if card != nil {
+				directives.WriteRune(char)
+				continue
+			}
+
+			if char == '@' && (i == 0 || unicode.IsSpace(runes[i-1])) {
+				name := readDirectiveName(runes[i+1:])
+
+				if _, ok := deck.DirectivePositions[name]; !ok {
+					deck.DirectivePositions[name] = position
+				}
+			}
+
+			header.WriteRune(char)
+		}
+	}
+
+	if block != nil {
+		report(errorUnbalanced, block.Position, "'(' is never closed")
+	}
+
+	if card != nil {
+		report(errorUnbalanced, card.Position, "'[' is never closed")
+	}
+
+	deck.Directives = strings.TrimSpace(header.String())
+
+	return deck, errs
+}
+
+// Block text without the indentation shared by its lines, so only the indentation within the block is kept.
+func dedent(text string) string {
+	lines := strings.Split(text, "\n")
+
+	// Text right after the opening parenthesis isn't indented like the lines below it
+	startsInline := strings.TrimSpace(lines[0]) != ""
+
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+
+	for i, line := range lines {
+		if (i == 0 && startsInline) || strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		width := len(line) - len(strings.TrimLeft(line, " \t"))
+
+		if indent == -1 || width < indent {
+			indent = width
+		}
+	}
+
+	for i, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+
+		if (i == 0 && startsInline) || line == "" {
+			line = strings.TrimLeft(line, " \t")
+		} else {
+			line = line[indent:]
+		}
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Text that reads back unchanged when written in a block.
+func escapeDeckText(text string) string {
+	var escaped strings.Builder
+
+	for _, char := range text {
+		if strings.ContainsRune(escapedCharacters, char) {
+			escaped.WriteRune('\\')
+		}
+
+		escaped.WriteRune(char)
+	}
+
+	return escaped.String()
+}
+
+// Byte offset of a position in the deck file, its length if the position is past the end.
+func positionOffset(data string, position Position) int {
+	line, column := 1, 1
+
+	for offset, char := range data {
+		if line == position.Line && column == position.Column {
+			return offset
+		}
+
+		if char == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	return len(data)
+}
+
+func readDirectiveName(runes []rune) string {
+	end := 0
+
+	for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune("[]()", runes[end]) {
+		end++
+	}
+
+	return string(runes[:end])
+}
+
+// Options from the header directives, an invalid one is reported where it's written.
+func (deck *parsedDeck) options() (DeckOptions, error) {
+	directives := parseDirectives(deck.Directives)
+	options, err := parseDeckOptions(directives)
+
+	if err == nil {
+		return options, nil
+	}
+
+	for name, value := range directives {
+		if _, err := parseDeckOptions(map[string]string{name: value}); err != nil {
+			return options, &ParseError{
+				Kind:     errorInvalidDirective,
+				Position: deck.DirectivePositions[name],
+				Message:  err.Error(),
+			}
+		}
+	}
+
+	return options, err
+}
+
+// Every problem in a deck file, including the ones that don't stop it from loading, such as duplicate cards.
+// Sorted by position.
+func lintDeck(data string) []*ParseError {
+	deck, errs := parseDeck(data)
+
+	if _, err := deck.options(); err != nil {
+		if parseErr, ok := err.(*ParseError); ok {
+			errs = append(errs, parseErr)
+		}
+	}
+
+	questions := make(map[string]Position)
+	ids := make(map[string]Position)
+
+	for _, card := range deck.Cards {
+		question := contentID(card.Blocks[0].Text)
+
+		if first, ok := questions[question]; ok {
+			errs = append(errs, &ParseError{
+				Kind:     errorDuplicateCard,
+				Position: card.Position,
+				Message:  fmt.Sprintf("duplicate question '%s', first at line %d", card.Blocks[0].Text, first.Line),
+			})
+		} else {
+			questions[question] = card.Position
+		}
+
+		id := parseDirectives(card.Directives)["id"]
+
+		if id == "" {
+			continue
+		}
+
+		if first, ok := ids[id]; ok {
+			errs = append(errs, &ParseError{
+				Kind:     errorDuplicateCard,
+				Position: card.Position,
+				Message:  fmt.Sprintf("duplicate ID '%s', first at line %d", id, first.Line),
+			})
+		} else {
+			ids[id] = card.Position
+		}
+	}
+
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+
+		return errs[i].Column < errs[j].Column
+	})
+
+	return errs
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeck(t *testing.T) {
+	data := "@boxes 5\n# a comment (with brackets]\n[ @id 1\n    ( (test) me )\n    (answer)\n]"
+
+	deck, errs := parseDeck(data)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, "@boxes 5", deck.Directives)
+	assert.Equal(t, Position{1, 1}, deck.DirectivePositions["boxes"])
+	assert.Equal(t, []parsedCard{{
+		Position: Position{3, 1},
+		Blocks: []parsedBlock{
+			{Position: Position{4, 5}, Text: "(test) me"},
+			{Position: Position{5, 5}, Text: "answer"},
+		},
+		Directives: "@id 1",
+		End:        Position{6, 1},
+	}}, deck.Cards)
+}
+
+func TestParseDeck_errors(t *testing.T) {
+	tests := []struct {
+		data    string
+		kind    ParseErrorKind
+		message string
+	}{
+		{"[(red) (sox)", errorUnbalanced, "line 1, column 1: '[' is never closed"},
+		{"[(red) (sox)]\n]", errorUnbalanced, "line 2, column 1: ']' without a matching '['"},
+		{"[(red) (sox))]", errorUnbalanced, "line 1, column 13: ')' without a matching '('"},
+		{"[(red) (sox]", errorUnbalanced, "line 1, column 8: '(' is never closed"},
+		{"[(red)\n[(blue) (jays)]", errorUnbalanced, "line 1, column 1: '[' is never closed"},
+		{"(red) [(blue) (jays)]", errorMalformedCard, "line 1, column 1: block outside of a card, cards are enclosed in [ ]"},
+		{"[(red) (sox) (wings)]", errorMalformedCard, "line 1, column 1: a card needs a question and an answer, found 3 blocks"},
+		{"[(red) ( )]", errorEmptySide, "line 1, column 8: empty block"},
+		{"[(red) (|)]", errorEmptySide, "line 1, column 8: block has nothing but separators of alternatives"},
+		{"[(Io {{c1::vado}} a {{c2:: | }})]", errorEmptySide, "line 1, column 2: gap {{c2:: | }} has nothing to answer"},
+	}
+
+	for _, test := range tests {
+		_, errs := parseDeck(test.data)
+
+		if assert.NotEmpty(t, errs, test.data) {
+			assert.Equal(t, test.kind, errs[0].Kind, test.data)
+			assert.EqualError(t, errs[0], test.message, test.data)
+		}
+	}
+}
+
+func TestParseDeck_keeps_valid_cards(t *testing.T) {
+	deck, errs := parseDeck("[(red)]\n[(blue) (jays)]")
+
+	assert.Len(t, errs, 1)
+	assert.Len(t, deck.Cards, 1)
+	assert.Equal(t, "blue", deck.Cards[0].Blocks[0].Text)
+}
+
+func TestParsedDeckOptions_invalid_directive(t *testing.T) {
+	deck, _ := parseDeck("@typos 1\n  @boxes many\n[(red) (sox)]")
+
+	_, err := deck.options()
+
+	assert.EqualError(t, err, "line 2, column 3: invalid number of boxes 'many'")
+}
+
+func TestLintDeck(t *testing.T) {
+	data := `[(red) (sox)]
+[@id x (blue) (jays)]
+[(Red) (wings)]
+[@id x (white) (sox)]
+[(green)]`
+
+	errs := lintDeck(data)
+
+	assert.Len(t, errs, 3)
+	assert.Equal(t, Position{3, 1}, errs[0].Position)
+	assert.Equal(t, errorDuplicateCard, errs[0].Kind)
+	assert.Equal(t, "duplicate question 'Red', first at line 1", errs[0].Message)
+	assert.Equal(t, "duplicate ID 'x', first at line 2", errs[1].Message)
+	assert.Equal(t, errorMalformedCard, errs[2].Kind)
+
+	assert.Empty(t, lintDeck("@boxes 2 [(red) (sox)]"))
+}
+
+func TestParseDeck_escapes(t *testing.T) {
+	deck, errs := parseDeck(`[ (andare \(informale\)) (to go \[away\] \\ leave \)) ]`)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, "andare (informale)", deck.Cards[0].Blocks[0].Text)
+	assert.Equal(t, `to go [away] \ leave )`, deck.Cards[0].Blocks[1].Text)
+
+	// Other characters keep the backslash
+	deck, _ = parseDeck(`[(C:\path) (\n)]`)
+	assert.Equal(t, `C:\path`, deck.Cards[0].Blocks[0].Text)
+	assert.Equal(t, `\n`, deck.Cards[0].Blocks[1].Text)
+
+	// An escaped pipe stays escaped until alternatives are split
+	deck, _ = parseDeck(`[(a \| b) (c)]`)
+	assert.Equal(t, `a \| b`, deck.Cards[0].Blocks[0].Text)
+}
+
+func TestEscapeDeckText(t *testing.T) {
+	text := `to go (informal) [a] \ b`
+
+	assert.Equal(t, `to go \(informal\) \[a\] \\ b`, escapeDeckText(text))
+
+	deck, errs := parseDeck(formatDeckEntry(Definition{From: text, To: ")"}))
+
+	assert.Empty(t, errs)
+	assert.Equal(t, text, deck.Cards[0].Blocks[0].Text)
+	assert.Equal(t, ")", deck.Cards[0].Blocks[1].Text)
+
+	// Pipes are escaped, so they aren't taken for separators of alternatives
+	assert.Equal(t, `a \| b`, escapeDeckText("a | b"))
+
+	deck, _ = parseDeck(formatDeckEntry(Definition{From: "pipe", To: "a | b"}))
+	assert.Equal(t, []string{"a | b"}, getAlternatives(deck.Cards[0].Blocks[1].Text))
+}
+
+func TestParseDeck_multiline_blocks(t *testing.T) {
+	data := `[
+    (andare)
+    (
+        io vado
+        tu vai
+          (informale)
+
+        lui va
+    )
+]
+[ (first line
+     second line) (x) ]`
+
+	deck, errs := parseDeck(data)
+
+	assert.Empty(t, errs)
+	assert.Equal(t, "io vado\ntu vai\n  (informale)\n\nlui va", deck.Cards[0].Blocks[1].Text)
+	assert.Equal(t, "first line\nsecond line", deck.Cards[1].Blocks[0].Text)
+}
+
+func TestDedent(t *testing.T) {
+	assert.Equal(t, "a", dedent("  a  "))
+	assert.Equal(t, "", dedent("  \n  "))
+	assert.Equal(t, "a\n  b", dedent("\n\t\ta\n\t\t  b\n\t"))
+}
+
+func TestPositionOffset(t *testing.T) {
+	data := "# città\n[(è) \\(x\\) (y)]"
+
+	assert.Equal(t, 0, positionOffset(data, Position{1, 1}))
+	assert.Equal(t, len("# città\n[(è) \\(x\\) "), positionOffset(data, Position{2, 12}))
+	assert.Equal(t, len(data), positionOffset(data, Position{5, 1}))
+}
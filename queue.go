@@ -0,0 +1,246 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// Part of a card every scheduler with per-card due dates keeps, the rest is specific to the algorithm.
+type ScheduledCard struct {
+	Definition Definition `json:"definition"`
+	Due        time.Time  `json:"due"`
+	Reviews    []Review   `json:"reviews"`
+}
+
+type queueCard[C any] interface {
+	*SM2Card | *FSRSCard
+
+	scheduled() *ScheduledCard
+
+	// Copy sharing nothing that changes while studying.
+	copy() C
+}
+
+// Cards due by date for SM-2 and FSRS, which only differ in how an answer changes a card's schedule.
+type dueQueue[C queueCard[C]] struct {
+	Cards map[string]C `json:"cards"`
+
+	// Cards answered wrong, in SM-2 with quality below 4, are repeated until they're answered correctly
+	// within the same session. It doesn't change their schedule any more.
+	relearning map[string]bool
+
+	// Cards skipped in the session, asked once no other card is due
+	skipped map[string]bool
+
+	CurrentCard C `json:"-"`
+}
+
+// Cards sorted by due date, the ones due earliest first.
+func (queue *dueQueue[C]) sortedCards() []C {
+	cards := make([]C, 0, len(queue.Cards))
+
+	for _, card := range queue.Cards {
+		cards = append(cards, card)
+	}
+
+	sort.Slice(cards, func(i, j int) bool {
+		a, b := cards[i].scheduled(), cards[j].scheduled()
+
+		if a.Due.Equal(b.Due) {
+			return a.Definition.ID < b.Definition.ID
+		}
+
+		return a.Due.Before(b.Due)
+	})
+
+	return cards
+}
+
+func (queue *dueQueue[C]) next() *Definition {
+	queue.CurrentCard = queue.pickCard()
+
+	// Only skipped cards are left, they're asked after all
+	if queue.CurrentCard == nil && len(queue.skipped) > 0 {
+		queue.skipped = nil
+		queue.CurrentCard = queue.pickCard()
+	}
+
+	if queue.CurrentCard == nil {
+		return nil
+	}
+
+	return &queue.CurrentCard.scheduled().Definition
+}
+
+// Whether a card can be asked now, only suspended and skipped cards can't.
+func (queue *dueQueue[C]) isAvailable(card *ScheduledCard) bool {
+	return !card.Definition.Suspended && !queue.skipped[card.Definition.ID]
+}
+
+// The card due earliest, or one being relearned. Suspended and skipped cards are left out.
+func (queue *dueQueue[C]) pickCard() C {
+	currentTime := now()
+	cards := queue.sortedCards()
+
+	for _, card := range cards {
+		if card.scheduled().Due.After(currentTime) {
+			break
+		}
+
+		if queue.isAvailable(card.scheduled()) {
+			return card
+		}
+	}
+
+	for _, card := range cards {
+		if queue.relearning[card.scheduled().Definition.ID] && queue.isAvailable(card.scheduled()) {
+			return card
+		}
+	}
+
+	return nil
+}
+
+// Leave the card returned by the last call to next until the other cards due are asked.
+func (queue *dueQueue[C]) skip() {
+	if queue.CurrentCard == nil {
+		return
+	}
+
+	if queue.skipped == nil {
+		queue.skipped = make(map[string]bool)
+	}
+
+	queue.skipped[queue.CurrentCard.scheduled().Definition.ID] = true
+	queue.CurrentCard = nil
+}
+
+// Whether the current card is being relearned, so the answer doesn't change its schedule and its grade
+// is recorded as a relearning step. A correct answer ends relearning, a wrong answer to a card that's
+// not relearned yet starts it.
+func (queue *dueQueue[C]) relearn(grade Grade, correct bool) bool {
+	if queue.relearning == nil {
+		queue.relearning = make(map[string]bool)
+	}
+
+	card := queue.CurrentCard.scheduled()
+	id := card.Definition.ID
+
+	if queue.relearning[id] {
+		card.Reviews = append(card.Reviews, Review{
+			Time:       now(),
+			Grade:      grade,
+			Relearning: true,
+		})
+
+		if correct {
+			delete(queue.relearning, id)
+		}
+
+		return true
+	}
+
+	if !correct {
+		queue.relearning[id] = true
+	}
+
+	return false
+}
+
+// Reviews that changed the schedule, relearning steps are left out.
+func (card *ScheduledCard) scheduledReviews() []Review {
+	reviews := []Review{}
+
+	for _, review := range card.Reviews {
+		if !review.Relearning {
+			reviews = append(reviews, review)
+		}
+	}
+
+	return reviews
+}
+
+// Copy sharing nothing that changes while studying, e.g. to undo an answer.
+func (queue *dueQueue[C]) cloneQueue() dueQueue[C] {
+	clone := dueQueue[C]{
+		Cards:      make(map[string]C),
+		relearning: make(map[string]bool),
+		skipped:    make(map[string]bool),
+	}
+
+	for id, card := range queue.Cards {
+		clone.Cards[id] = card.copy()
+	}
+
+	if queue.CurrentCard != nil {
+		clone.CurrentCard = clone.Cards[queue.CurrentCard.scheduled().Definition.ID]
+	}
+
+	for id := range queue.relearning {
+		clone.relearning[id] = true
+	}
+
+	for id := range queue.skipped {
+		clone.skipped[id] = true
+	}
+
+	return clone
+}
+
+func (queue *dueQueue[C]) nextDue() time.Time {
+	for _, card := range queue.sortedCards() {
+		if !card.scheduled().Definition.Suspended {
+			return card.scheduled().Due
+		}
+	}
+
+	return time.Time{}
+}
+
+func (queue *dueQueue[C]) dueDates() map[string]time.Time {
+	dates := make(map[string]time.Time)
+
+	for id, card := range queue.Cards {
+		dates[id] = card.scheduled().Due
+	}
+
+	return dates
+}
+
+// Bring the cards in line with definitions from the deck file, new ones are created with newCard.
+func (queue *dueQueue[C]) reconcileCards(definitions []Definition, newCard func(ScheduledCard) C) *Reconciliation {
+	reconciliation := &Reconciliation{}
+
+	if queue.Cards == nil {
+		queue.Cards = make(map[string]C)
+	}
+
+	wanted := make(map[string]bool)
+
+	for _, def := range definitions {
+		wanted[def.ID] = true
+
+		card, ok := queue.Cards[def.ID]
+
+		if !ok {
+			queue.Cards[def.ID] = newCard(ScheduledCard{
+				Definition: def,
+				Due:        startOfDay(now()),
+				Reviews:    []Review{},
+			})
+			reconciliation.Added = append(reconciliation.Added, def)
+		} else if card.scheduled().Definition != def {
+			card.scheduled().Definition = def
+			reconciliation.Updated = append(reconciliation.Updated, def)
+		}
+	}
+
+	for id, card := range queue.Cards {
+		if !wanted[id] {
+			delete(queue.Cards, id)
+			reconciliation.Removed = append(reconciliation.Removed, card.scheduled().Definition)
+		}
+	}
+
+	return reconciliation
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// One answer, as written to the review log.
+type ReviewLogEntry struct {
+	Time      time.Time `json:"time"`
+	Algorithm string    `json:"algorithm"`
+	CardID    string    `json:"card_id"`
+	Direction string    `json:"direction"`
+	Answer    string    `json:"answer"`
+	Result    Match     `json:"result"`
+	Grade     Grade     `json:"grade"`
+	BoxBefore int       `json:"box_before"`
+	BoxAfter  int       `json:"box_after"`
+
+	// From showing the question to submitting the answer, in milliseconds
+	ResponseTime int64 `json:"response_ms"`
+}
+
+func reviewLogPath(deckPath string) string {
+	return fmt.Sprintf("%s.reviews.jsonl", deckPath)
+}
+
+// Entries are only ever appended, one JSON object per line.
+func appendReviewLog(path string, entry ReviewLogEntry) error {
+	line, err := json.Marshal(entry)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(append(line, '\n'))
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// Returns no entries if the log doesn't exist yet.
+// A broken last line, left by a crash while it was written, is skipped.
+func loadReviewLog(path string) ([]ReviewLogEntry, error) {
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return []ReviewLogEntry{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	entries := []ReviewLogEntry{}
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	var broken error
+
+	for scanner.Scan() {
+		lineNo++
+
+		if broken != nil {
+			return nil, broken
+		}
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry ReviewLogEntry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			broken = fmt.Errorf("%s:%d: %s", path, lineNo, err)
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Entry undoing an answer, the log is never rewritten. The card moves back to where it was before the answer.
+func undoneEntry(answer ReviewLogEntry) ReviewLogEntry {
+	return ReviewLogEntry{
+		Time:      now(),
+		Algorithm: answer.Algorithm,
+		CardID:    answer.CardID,
+		Direction: answer.Direction,
+		Result:    matchUndone,
+		Grade:     answer.Grade,
+		BoxBefore: answer.BoxAfter,
+		BoxAfter:  answer.BoxBefore,
+	}
+}
+
+// Answers that weren't undone: every undone entry takes away the last answer to its card before it.
+func withoutUndone(entries []ReviewLogEntry) []ReviewLogEntry {
+	answers := []ReviewLogEntry{}
+
+	for _, entry := range entries {
+		if entry.Result != matchUndone {
+			answers = append(answers, entry)
+			continue
+		}
+
+		for i := len(answers) - 1; i >= 0; i-- {
+			if answers[i].CardID == entry.CardID && answers[i].Direction == entry.Direction {
+				answers = append(answers[:i], answers[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return answers
+}
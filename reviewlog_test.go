@@ -0,0 +1,112 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewLog_append_and_load(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.reviews.jsonl")
+	first := ReviewLogEntry{
+		Time:         time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC),
+		Algorithm:    algorithmLeitner,
+		CardID:       "andare",
+		Direction:    directionForward,
+		Answer:       "to og",
+		Result:       matchAlmost,
+		Grade:        gradeHard,
+		BoxBefore:    1,
+		BoxAfter:     1,
+		ResponseTime: 2500,
+	}
+	second := first
+	second.Result = matchWrong
+	second.Grade = gradeAgain
+
+	assert.Nil(t, appendReviewLog(path, first))
+	assert.Nil(t, appendReviewLog(path, second))
+
+	entries, err := loadReviewLog(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ReviewLogEntry{first, second}, entries)
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(data), `"result":"almost","grade":"hard"`)
+}
+
+func TestLoadReviewLog_missing_file(t *testing.T) {
+	entries, err := loadReviewLog(filepath.Join("nonexistent", "deck.reviews.jsonl"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []ReviewLogEntry{}, entries)
+}
+
+func TestLoadReviewLog_broken_lines(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.reviews.jsonl")
+	entry := `{"time":"2020-03-01T10:00:00Z","card_id":"andare","result":"exact","grade":"good"}`
+
+	// Cut off while it was written
+	ioutil.WriteFile(path, []byte(entry+"\n"+`{"time":"2020-03-01T10:01`), 0644)
+	entries, err := loadReviewLog(path)
+
+	assert.Nil(t, err)
+	assert.Len(t, entries, 1)
+
+	ioutil.WriteFile(path, []byte(`{"time":`+"\n"+entry+"\n"), 0644)
+	_, err = loadReviewLog(path)
+
+	assert.NotNil(t, err)
+}
+
+func TestWithoutUndone(t *testing.T) {
+	defer setNow(time.Date(2020, 3, 1, 10, 5, 0, 0, time.UTC))()
+
+	day := time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC)
+	first := ReviewLogEntry{Time: day, CardID: "andare", Direction: directionForward, Result: matchWrong, Grade: gradeAgain, BoxBefore: 1}
+	second := ReviewLogEntry{Time: day, CardID: "andare", Direction: directionForward, Result: matchExact, Grade: gradeGood, BoxAfter: 1}
+	other := ReviewLogEntry{Time: day, CardID: "essere", Direction: directionForward, Result: matchExact, Grade: gradeGood}
+
+	undone := undoneEntry(second)
+
+	assert.Equal(t, ReviewLogEntry{
+		Time:      now(),
+		CardID:    "andare",
+		Direction: directionForward,
+		Result:    matchUndone,
+		Grade:     gradeGood,
+		BoxBefore: 1,
+	}, undone)
+
+	// Only the last answer to the card is taken away
+	assert.Equal(t, []ReviewLogEntry{first, other}, withoutUndone([]ReviewLogEntry{first, second, other, undone}))
+	assert.Equal(t, []ReviewLogEntry{other}, withoutUndone([]ReviewLogEntry{other, undone}))
+}
+
+func TestReviewLog_undone_entry(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	path := filepath.Join(dir, "deck.reviews.jsonl")
+	entry := undoneEntry(ReviewLogEntry{CardID: "andare", Grade: gradeGood})
+
+	assert.Nil(t, appendReviewLog(path, entry))
+
+	data, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(data), `"result":"undone"`)
+
+	entries, err := loadReviewLog(path)
+
+	assert.Nil(t, err)
+	assert.Equal(t, matchUndone, entries[0].Result)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	algorithmLeitner = "leitner"
+	algorithmSM2     = "sm2"
+	algorithmFSRS    = "fsrs"
+)
+
+// Self-assessment of an answer, from forgotten to effortless.
+type Grade int
+
+const (
+	gradeAgain Grade = iota + 1
+	gradeHard
+	gradeGood
+	gradeEasy
+)
+
+var gradeNames = map[Grade]string{
+	gradeAgain: "again",
+	gradeHard:  "hard",
+	gradeGood:  "good",
+	gradeEasy:  "easy",
+}
+
+var grades = []Grade{gradeAgain, gradeHard, gradeGood, gradeEasy}
+
+func (grade Grade) String() string {
+	return gradeNames[grade]
+}
+
+// Grades are stored by name in history files.
+func (grade Grade) MarshalText() ([]byte, error) {
+	name, ok := gradeNames[grade]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown grade %d", grade)
+	}
+
+	return []byte(name), nil
+}
+
+func (grade *Grade) UnmarshalText(text []byte) error {
+	parsed, ok := parseGrade(string(text))
+
+	if !ok {
+		return fmt.Errorf("unknown grade '%s'", text)
+	}
+
+	*grade = parsed
+
+	return nil
+}
+
+// Accepts a grade name, its first letter or its number (1 - again, ..., 4 - easy).
+func parseGrade(text string) (Grade, bool) {
+	for _, grade := range grades {
+		name := grade.String()
+
+		if text == name || text == name[:1] || text == fmt.Sprint(int(grade)) {
+			return grade, true
+		}
+	}
+
+	return 0, false
+}
+
+type Review struct {
+	Time  time.Time `json:"time"`
+	Grade Grade     `json:"grade"`
+
+	// Answer to a card repeated in the session it was answered wrong, it didn't change the schedule
+	Relearning bool `json:"relearning,omitempty"`
+}
+
+// Replaced in tests to control the calendar.
+var now = time.Now
+
+// Decides which definition to ask next and how answers change the schedule.
+type Scheduler interface {
+	// Pick the next definition to ask, nil if there's nothing left to study right now.
+	// Suspended definitions are never picked.
+	next() *Definition
+
+	// Update the schedule of the definition returned by the last call to next.
+	record(grade Grade)
+
+	// Leave the definition returned by the last call to next unanswered, to be asked later.
+	skip()
+
+	// Bring the schedule in line with definitions from the deck file.
+	reconcile(definitions []Definition) *Reconciliation
+
+	// Earliest time any definition becomes due, zero time if the deck is empty.
+	nextDue() time.Time
+
+	// When each definition becomes due by ID, zero time for the ones never asked.
+	dueDates() map[string]time.Time
+
+	// How far a definition got: its Leitner box, or the number of correct answers in a row.
+	// -1 if there's no such definition.
+	level(id string) int
+}
+
+func isValidAlgorithm(algorithm string) bool {
+	return algorithm == algorithmLeitner || algorithm == algorithmSM2 || algorithm == algorithmFSRS
+}
+
+// Midnight of the given day, in local time.
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGrade(t *testing.T) {
+	for _, text := range []string{"easy", "e", "4"} {
+		grade, ok := parseGrade(text)
+
+		assert.True(t, ok)
+		assert.Equal(t, gradeEasy, grade)
+	}
+
+	_, ok := parseGrade("5")
+
+	assert.False(t, ok)
+}
+
+func TestGrade_stored_by_name(t *testing.T) {
+	data, err := json.Marshal(map[Grade]BoxMove{gradeAgain: {Reset: true}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"again":{"reset":true}}`, string(data))
+
+	var review Review
+
+	err = json.Unmarshal([]byte(`{"time": "2020-05-10T00:00:00Z", "grade": "hard"}`), &review)
+
+	assert.Nil(t, err)
+	assert.Equal(t, Review{Time: time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC), Grade: gradeHard}, review)
+}
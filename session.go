@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const deckExtension = ".deck"
+
+// A deck studied in the session, with its own results.
+type StudyDeck struct {
+	path string
+	deck *Deck
+
+	correctAnswers int
+	wrongAnswers   int
+
+	// Nothing more is due in it this session
+	done bool
+}
+
+// Deck files named by paths, directories (every .deck file in them) and globs, each listed once,
+// even when it's named in different ways, e.g. "decks/a.deck" and "./decks/a.deck".
+func expandDeckPaths(patterns []string) ([]string, error) {
+	paths := []string{}
+	seen := make(map[string]bool)
+
+	add := func(path string) {
+		key, err := filepath.Abs(path)
+
+		if err != nil {
+			key = filepath.Clean(path)
+		}
+
+		if !seen[key] {
+			seen[key] = true
+			paths = append(paths, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			matches, _ := filepath.Glob(filepath.Join(pattern, "*"+deckExtension))
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no decks found in '%s'", pattern)
+			}
+
+			sort.Strings(matches)
+
+			for _, match := range matches {
+				add(match)
+			}
+
+			continue
+		}
+
+		if strings.ContainsAny(pattern, "*?[") {
+			matches, err := filepath.Glob(pattern)
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern '%s': %s", pattern, err)
+			}
+
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no decks match '%s'", pattern)
+			}
+
+			sort.Strings(matches)
+
+			for _, match := range matches {
+				add(match)
+			}
+
+			continue
+		}
+
+		add(pattern)
+	}
+
+	return paths, nil
+}
+
+func deckName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), deckExtension)
+}
+
+// Decks take turns, so due cards of all of them are mixed. Returns nil once none of them has anything due.
+func (session *Session) nextQuestion(command *CommandLine) (*StudyDeck, *Question) {
+	for range session.decks {
+		study := session.decks[session.turn%len(session.decks)]
+		session.turn++
+
+		if study.done {
+			continue
+		}
+
+		question := prepareQuestion(command, study.deck)
+
+		if question == nil {
+			study.done = true
+			continue
+		}
+
+		return study, question
+	}
+
+	return nil, nil
+}
+
+// Earliest time any deck has something due, zero time if all of them are empty.
+func (session *Session) nextDue() time.Time {
+	var earliest time.Time
+
+	for _, study := range session.decks {
+		due := study.deck.scheduler().nextDue()
+
+		if !due.IsZero() && (earliest.IsZero() || due.Before(earliest)) {
+			earliest = due
+		}
+	}
+
+	return earliest
+}
+
+func printDeckResults(decks []*StudyDeck) {
+	for _, study := range decks {
+		fmt.Printf("\t%s: %d correct, %d wrong", deckName(study.path), study.correctAnswers, study.wrongAnswers)
+
+		if total := study.correctAnswers + study.wrongAnswers; total != 0 {
+			fmt.Printf(" (%0.2f%%)", float64(study.correctAnswers)/float64(total)*100)
+		}
+
+		fmt.Println()
+	}
+}
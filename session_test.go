@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandDeckPaths(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	for _, name := range []string{"b.deck", "a.deck", "notes.txt"} {
+		ioutil.WriteFile(filepath.Join(dir, name), []byte{}, 0644)
+	}
+
+	a := filepath.Join(dir, "a.deck")
+	b := filepath.Join(dir, "b.deck")
+
+	paths, err := expandDeckPaths([]string{dir})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{a, b}, paths)
+
+	paths, err = expandDeckPaths([]string{b, filepath.Join(dir, "*.deck")})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{b, a}, paths)
+
+	// The same file named relative to the working directory
+	cwd, _ := os.Getwd()
+	relative, _ := filepath.Rel(cwd, b)
+
+	paths, err = expandDeckPaths([]string{dir, "./" + relative})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{a, b}, paths)
+
+	paths, err = expandDeckPaths([]string{"other.deck"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"other.deck"}, paths)
+
+	_, err = expandDeckPaths([]string{filepath.Join(dir, "*.txt.deck")})
+	assert.NotNil(t, err)
+}
+
+func TestDeckName(t *testing.T) {
+	assert.Equal(t, "test_ita", deckName("decks/test_ita.deck"))
+	assert.Equal(t, "words.txt", deckName("words.txt"))
+}
+
+func TestSession_decks_take_turns(t *testing.T) {
+	first := &Deck{Leitner: initLeitner(3, []Definition{defToGo, defToBe})}
+	second := &Deck{Leitner: initLeitner(3, []Definition{defToSee})}
+
+	session := getSession()
+	session.decks = []*StudyDeck{{path: "first.deck", deck: first}, {path: "second.deck", deck: second}}
+	command := getCommand("standard")
+
+	asked := []string{}
+
+	for {
+		study, question := session.nextQuestion(command)
+
+		if question == nil {
+			break
+		}
+
+		asked = append(asked, deckName(study.path)+" "+question.Text)
+		recordAnswer(gradeGood, session, study.deck.scheduler())
+	}
+
+	assert.Equal(t, []string{"first essere", "second vedere", "first andare"}, asked)
+	assert.True(t, session.decks[1].done)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+)
+
+const (
+	sm2InitialEaseFactor = 2.5
+	sm2MinEaseFactor     = 1.3
+)
+
+// Answer quality on the SuperMemo 0-5 scale given for every grade.
+func getDefaultQualities() map[Grade]int {
+	return map[Grade]int{
+		gradeAgain: 1,
+		gradeHard:  3,
+		gradeGood:  4,
+		gradeEasy:  5,
+	}
+}
+
+type SM2Card struct {
+	ScheduledCard
+
+	EaseFactor  float64 `json:"ease_factor"`
+	Interval    int     `json:"interval"`
+	Repetitions int     `json:"repetitions"`
+}
+
+func (card *SM2Card) scheduled() *ScheduledCard {
+	return &card.ScheduledCard
+}
+
+func (card *SM2Card) copy() *SM2Card {
+	clone := *card
+	clone.Reviews = append([]Review{}, card.Reviews...)
+
+	return &clone
+}
+
+// SuperMemo 2 scheduling: every card has its own ease factor and is asked again after an interval
+// (in days) that grows with each successful repetition.
+type SM2 struct {
+	dueQueue[*SM2Card]
+
+	// Quality of the answer for every grade
+	Qualities map[Grade]int `json:"qualities"`
+}
+
+func initSM2(definitions []Definition) *SM2 {
+	sm2 := &SM2{
+		Qualities: getDefaultQualities(),
+	}
+
+	sm2.reconcile(definitions)
+
+	return sm2
+}
+
+func newSM2Card(card ScheduledCard) *SM2Card {
+	return &SM2Card{
+		ScheduledCard: card,
+		EaseFactor:    sm2InitialEaseFactor,
+	}
+}
+
+// Copy sharing nothing that changes while studying, e.g. to undo an answer.
+func (sm2 *SM2) clone() *SM2 {
+	clone := *sm2
+	clone.dueQueue = sm2.cloneQueue()
+
+	return &clone
+}
+
+func (sm2 *SM2) record(grade Grade) {
+	card := sm2.CurrentCard
+
+	if card == nil {
+		return
+	}
+
+	quality := sm2.Qualities[grade]
+
+	if sm2.relearn(grade, quality >= 4) {
+		return
+	}
+
+	card.Reviews = append(card.Reviews, Review{
+		Time:  now(),
+		Grade: grade,
+	})
+
+	if quality >= 3 {
+		switch card.Repetitions {
+		case 0:
+			card.Interval = 1
+		case 1:
+			card.Interval = 6
+		default:
+			card.Interval = int(math.Round(float64(card.Interval) * card.EaseFactor))
+		}
+
+		card.Repetitions++
+	} else {
+		card.Repetitions = 0
+		card.Interval = 1
+	}
+
+	card.EaseFactor += 0.1 - float64(5-quality)*(0.08+float64(5-quality)*0.02)
+
+	if card.EaseFactor < sm2MinEaseFactor {
+		card.EaseFactor = sm2MinEaseFactor
+	}
+
+	card.Due = startOfDay(now()).AddDate(0, 0, card.Interval)
+}
+
+func (sm2 *SM2) level(id string) int {
+	card, ok := sm2.Cards[id]
+
+	if !ok {
+		return -1
+	}
+
+	return card.Repetitions
+}
+
+func (sm2 *SM2) reconcile(definitions []Definition) *Reconciliation {
+	if sm2.Qualities == nil {
+		sm2.Qualities = getDefaultQualities()
+	}
+
+	return sm2.reconcileCards(definitions, newSM2Card)
+}
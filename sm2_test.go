@@ -0,0 +1,209 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setNow(t time.Time) func() {
+	previous := now
+	now = func() time.Time { return t }
+
+	return func() { now = previous }
+}
+
+func TestInitSM2(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	sm2 := initSM2(definitions)
+
+	assert.Equal(t, len(definitions), len(sm2.Cards))
+
+	card := sm2.Cards["andare"]
+
+	assert.Equal(t, defToGo, card.Definition)
+	assert.Equal(t, sm2InitialEaseFactor, card.EaseFactor)
+	assert.Equal(t, 0, card.Interval)
+	assert.Equal(t, time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC), card.Due)
+}
+
+func TestSM2_intervals_grow_with_correct_answers(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	restore := setNow(day)
+	defer restore()
+
+	sm2 := initSM2([]Definition{defToGo})
+
+	assert.Equal(t, &defToGo, sm2.next())
+	sm2.record(gradeGood)
+
+	card := sm2.Cards["andare"]
+
+	assert.Equal(t, 1, card.Interval)
+	assert.Equal(t, 1, card.Repetitions)
+	assert.Equal(t, time.Date(2020, 5, 11, 0, 0, 0, 0, time.UTC), card.Due)
+
+	// Not due again until tomorrow
+	assert.Nil(t, sm2.next())
+
+	setNow(day.AddDate(0, 0, 1))
+	assert.Equal(t, &defToGo, sm2.next())
+	sm2.record(gradeGood)
+
+	assert.Equal(t, 6, card.Interval)
+	assert.Equal(t, 2, card.Repetitions)
+
+	setNow(day.AddDate(0, 0, 7))
+	assert.Equal(t, &defToGo, sm2.next())
+	sm2.record(gradeGood)
+
+	assert.Equal(t, 15, card.Interval)
+	assert.Equal(t, 3, card.Repetitions)
+	assert.Equal(t, sm2InitialEaseFactor, card.EaseFactor)
+}
+
+func TestSM2_wrong_answer_is_repeated_in_session(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	sm2 := initSM2([]Definition{defToGo, defToBe})
+
+	assert.Equal(t, &defToGo, sm2.next())
+	sm2.record(gradeAgain)
+
+	card := sm2.Cards["andare"]
+
+	assert.Equal(t, 1, card.Interval)
+	assert.Equal(t, 0, card.Repetitions)
+	assert.InDelta(t, 1.96, card.EaseFactor, 0.0001)
+
+	assert.Equal(t, &defToBe, sm2.next())
+	sm2.record(gradeGood)
+
+	// Repeated until it's answered correctly, without changing the schedule
+	assert.Equal(t, &defToGo, sm2.next())
+	sm2.record(gradeAgain)
+	assert.Equal(t, &defToGo, sm2.next())
+	sm2.record(gradeGood)
+
+	assert.InDelta(t, 1.96, card.EaseFactor, 0.0001)
+	assert.Nil(t, sm2.next())
+}
+
+func TestSM2_ease_factor_has_lower_bound(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	sm2 := initSM2([]Definition{defToGo})
+
+	for i := 0; i < 5; i++ {
+		sm2.CurrentCard = sm2.Cards["andare"]
+		sm2.relearning = nil
+		sm2.record(gradeAgain)
+	}
+
+	assert.Equal(t, sm2MinEaseFactor, sm2.Cards["andare"].EaseFactor)
+}
+
+func TestSM2_reconcile(t *testing.T) {
+	sm2 := initSM2([]Definition{defToGo, defToBe})
+	sm2.Cards["andare"].Interval = 6
+
+	edited := Definition{ID: "andare", From: "andare", To: "to walk"}
+	reconciliation := sm2.reconcile([]Definition{edited, defToSee})
+
+	assert.Equal(t, []Definition{defToSee}, reconciliation.Added)
+	assert.Equal(t, []Definition{edited}, reconciliation.Updated)
+	assert.Equal(t, []Definition{defToBe}, reconciliation.Removed)
+	assert.Equal(t, edited, sm2.Cards["andare"].Definition)
+	assert.Equal(t, 6, sm2.Cards["andare"].Interval)
+	assert.Equal(t, 2, len(sm2.Cards))
+}
+
+func TestSM2_grades(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	sm2 := initSM2([]Definition{defToGo, defToBe})
+
+	sm2.CurrentCard = sm2.Cards["andare"]
+	sm2.record(gradeEasy)
+	sm2.CurrentCard = sm2.Cards["essere"]
+	sm2.record(gradeHard)
+
+	assert.InDelta(t, 2.6, sm2.Cards["andare"].EaseFactor, 0.0001)
+	assert.InDelta(t, 2.36, sm2.Cards["essere"].EaseFactor, 0.0001)
+	assert.Equal(t, gradeEasy, sm2.Cards["andare"].Reviews[0].Grade)
+
+	// Answered with quality below 4, so it's repeated in the session
+	assert.True(t, sm2.relearning["essere"])
+	assert.False(t, sm2.relearning["andare"])
+
+	// Grades of relearning steps are recorded without changing the schedule
+	interval := sm2.Cards["essere"].Interval
+
+	sm2.CurrentCard = sm2.Cards["essere"]
+	sm2.record(gradeGood)
+
+	assert.Equal(t, interval, sm2.Cards["essere"].Interval)
+	assert.Equal(t, []Review{
+		{Time: now(), Grade: gradeHard},
+		{Time: now(), Grade: gradeGood, Relearning: true},
+	}, sm2.Cards["essere"].Reviews)
+	assert.False(t, sm2.relearning["essere"])
+}
+
+func TestSM2Level(t *testing.T) {
+	sm2 := initSM2([]Definition{defToGo})
+
+	assert.Equal(t, 0, sm2.level("andare"))
+	assert.Equal(t, -1, sm2.level("vedere"))
+
+	sm2.next()
+	sm2.record(gradeGood)
+
+	assert.Equal(t, 1, sm2.level("andare"))
+}
+
+func TestSM2_leaves_out_suspended_cards(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	suspended := Definition{ID: "andare", From: "andare", To: "to go", Suspended: true}
+	scheduler := initSM2([]Definition{suspended, defToBe})
+
+	assert.Equal(t, &defToBe, scheduler.next())
+	scheduler.record(gradeGood)
+
+	assert.Nil(t, scheduler.next())
+	assert.Equal(t, scheduler.Cards["essere"].Due, scheduler.nextDue())
+}
+
+func TestSM2Skip(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	scheduler := initSM2([]Definition{defToGo, defToBe})
+
+	assert.Equal(t, &defToGo, scheduler.next())
+	scheduler.skip()
+
+	assert.Equal(t, &defToBe, scheduler.next())
+	scheduler.skip()
+
+	// Only skipped cards are left
+	assert.Equal(t, &defToGo, scheduler.next())
+}
+
+func TestSM2Clone(t *testing.T) {
+	defer setNow(time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC))()
+
+	scheduler := initSM2([]Definition{defToGo, defToBe})
+	scheduler.next()
+
+	clone := scheduler.clone()
+	scheduler.record(gradeAgain)
+
+	assert.Empty(t, clone.Cards["andare"].Reviews)
+	assert.Empty(t, clone.relearning)
+	assert.Equal(t, clone.Cards["andare"], clone.CurrentCard)
+	assert.Len(t, scheduler.Cards["andare"].Reviews, 1)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/logrusorgru/aurora"
+)
+
+const (
+	statsDays         = 14
+	statsWeeks        = 8
+	statsHardestCards = 10
+	statsBarWidth     = 40
+)
+
+// Reviews in a period of one or more days.
+type PeriodStats struct {
+	Start   time.Time
+	Reviews int
+	Correct int
+}
+
+func (period PeriodStats) accuracy() float64 {
+	return float64(period.Correct) / float64(period.Reviews) * 100
+}
+
+type CardStats struct {
+	ID        string
+	Reviews   int
+	Wrong     int
+	Demotions int
+}
+
+// Reviews in count periods of the given number of days, the last one ending today.
+func reviewsByPeriod(entries []ReviewLogEntry, days int, count int) []PeriodStats {
+	start := startOfDay(now()).AddDate(0, 0, -(days*count - 1))
+	periods := make([]PeriodStats, count)
+
+	for i := range periods {
+		periods[i].Start = start.AddDate(0, 0, i*days)
+	}
+
+	for _, entry := range entries {
+		if entry.Time.Before(start) {
+			continue
+		}
+
+		i := int(daysBetween(start, startOfDay(entry.Time.In(start.Location())))+0.5) / days
+
+		if i >= count {
+			continue
+		}
+
+		periods[i].Reviews++
+
+		if entry.Grade != gradeAgain {
+			periods[i].Correct++
+		}
+	}
+
+	return periods
+}
+
+// Cards moved back to a lower box most often, then the ones answered wrong most often.
+func hardestCards(entries []ReviewLogEntry, limit int) []CardStats {
+	cards := make(map[string]*CardStats)
+
+	for _, entry := range entries {
+		card, ok := cards[entry.CardID]
+
+		if !ok {
+			card = &CardStats{ID: entry.CardID}
+			cards[entry.CardID] = card
+		}
+
+		card.Reviews++
+
+		if entry.Grade == gradeAgain {
+			card.Wrong++
+		}
+
+		if entry.BoxAfter < entry.BoxBefore {
+			card.Demotions++
+		}
+	}
+
+	hardest := []CardStats{}
+
+	for _, card := range cards {
+		if card.Demotions > 0 || card.Wrong > 0 {
+			hardest = append(hardest, *card)
+		}
+	}
+
+	sort.Slice(hardest, func(i, j int) bool {
+		if hardest[i].Demotions != hardest[j].Demotions {
+			return hardest[i].Demotions > hardest[j].Demotions
+		}
+
+		if hardest[i].Wrong != hardest[j].Wrong {
+			return hardest[i].Wrong > hardest[j].Wrong
+		}
+
+		return hardest[i].ID < hardest[j].ID
+	})
+
+	if len(hardest) > limit {
+		hardest = hardest[:limit]
+	}
+
+	return hardest
+}
+
+// Number of cards coming due on each of the next days, starting today.
+// Cards that are already due count as due today.
+func forecast(dueDates map[string]time.Time, days int) []int {
+	today := startOfDay(now())
+	counts := make([]int, days)
+
+	for _, due := range dueDates {
+		day := 0
+
+		if due.After(today) {
+			day = int(daysBetween(today, startOfDay(due.In(today.Location()))) + 0.5)
+		}
+
+		if day < days {
+			counts[day]++
+		}
+	}
+
+	return counts
+}
+
+func bar(value int, max int) string {
+	if max == 0 {
+		return ""
+	}
+
+	width := value * statsBarWidth / max
+
+	if width == 0 && value > 0 {
+		width = 1
+	}
+
+	return strings.Repeat("#", width)
+}
+
+func maxCount(counts []int) int {
+	max := 0
+
+	for _, count := range counts {
+		if count > max {
+			max = count
+		}
+	}
+
+	return max
+}
+
+func printBoxes(leitner *Leitner) {
+	fmt.Println(aurora.Blue("Cards per box"))
+
+	counts := []int{}
+
+	for _, box := range leitner.Boxes {
+		counts = append(counts, len(box.Definitions))
+	}
+
+	for i, count := range counts {
+		fmt.Printf("\tBox %d\t%4d %s\n", i+1, count, bar(count, maxCount(counts)))
+	}
+
+	fmt.Println()
+}
+
+func printAccuracy(weeks []PeriodStats) {
+	fmt.Println(aurora.Blue("Accuracy per week"))
+
+	for _, week := range weeks {
+		if week.Reviews == 0 {
+			fmt.Printf("\t%s\t   -\n", week.Start.Format("2006-01-02"))
+			continue
+		}
+
+		fmt.Printf("\t%s\t%3.0f%% (%d reviews)\n", week.Start.Format("2006-01-02"), week.accuracy(), week.Reviews)
+	}
+
+	fmt.Println()
+}
+
+func printHardestCards(cards []CardStats, definitions map[string]Definition) {
+	fmt.Println(aurora.Blue("Hardest cards"))
+
+	if len(cards) == 0 {
+		fmt.Printf("\tNone yet\n\n")
+		return
+	}
+
+	for _, card := range cards {
+		def, ok := definitions[card.ID]
+
+		if !ok {
+			// Removed from the deck since
+			continue
+		}
+
+		fmt.Printf("\t%s -> %s\tdemotions: %d, wrong: %d of %d\n",
+			formatOneLine(def.From), formatOneLine(def.To), card.Demotions, card.Wrong, card.Reviews)
+	}
+
+	fmt.Println()
+}
+
+func printReviewsPerDay(days []PeriodStats) {
+	fmt.Println(aurora.Blue("Reviews per day"))
+
+	counts := []int{}
+
+	for _, day := range days {
+		counts = append(counts, day.Reviews)
+	}
+
+	for i, day := range days {
+		fmt.Printf("\t%s\t%4d %s\n", day.Start.Format("Mon 02 Jan"), counts[i], bar(counts[i], maxCount(counts)))
+	}
+
+	fmt.Println()
+}
+
+func printForecast(counts []int) {
+	fmt.Println(aurora.Blue("Due in the next days"))
+
+	today := startOfDay(now())
+
+	for i, count := range counts {
+		fmt.Printf("\t%s\t%4d %s\n", today.AddDate(0, 0, i).Format("Mon 02 Jan"), count, bar(count, maxCount(counts)))
+	}
+
+	fmt.Println()
+}
+
+func statsCommand(args []string) error {
+	flags := newFlagSet("stats", "-deck-path <deck>")
+	deckPath := flags.String("deck-path", "", "Path to deck file")
+
+	flags.Parse(args)
+
+	history, err := loadHistory(historyPath(*deckPath))
+
+	if err != nil {
+		return fmt.Errorf("cannot load the deck history file %s", err)
+	}
+
+	if history == nil {
+		return errors.New("no history found, study the deck first")
+	}
+
+	entries, err := loadReviewLog(reviewLogPath(*deckPath))
+
+	if err != nil {
+		return fmt.Errorf("cannot load the review log %s", err)
+	}
+
+	entries = withoutUndone(entries)
+
+	// Leitner boxes hold every definition, whichever algorithm is used
+	definitions := make(map[string]Definition)
+
+	for _, box := range history.Leitner.Boxes {
+		for _, def := range box.Definitions {
+			definitions[def.ID] = def
+		}
+	}
+
+	if history.Algorithm == "" {
+		history.Algorithm = algorithmLeitner
+	}
+
+	printBoxes(history.Leitner)
+	printAccuracy(reviewsByPeriod(entries, 7, statsWeeks))
+	printHardestCards(hardestCards(entries, statsHardestCards), definitions)
+	printReviewsPerDay(reviewsByPeriod(entries, 1, statsDays))
+	printActivity(entries)
+	printForecast(forecast(history.scheduler().dueDates(), statsDays))
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReviewsByPeriod(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	entries := []ReviewLogEntry{
+		{Time: day.AddDate(0, 0, -20), Grade: gradeGood},
+		{Time: day.AddDate(0, 0, -2), Grade: gradeAgain},
+		{Time: day.AddDate(0, 0, -2), Grade: gradeHard},
+		{Time: day.Add(-time.Hour), Grade: gradeGood},
+	}
+
+	days := reviewsByPeriod(entries, 1, 3)
+
+	assert.Equal(t, []PeriodStats{
+		{Start: time.Date(2020, 5, 8, 0, 0, 0, 0, time.UTC), Reviews: 2, Correct: 1},
+		{Start: time.Date(2020, 5, 9, 0, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2020, 5, 10, 0, 0, 0, 0, time.UTC), Reviews: 1, Correct: 1},
+	}, days)
+
+	weeks := reviewsByPeriod(entries, 7, 2)
+
+	assert.Equal(t, time.Date(2020, 4, 27, 0, 0, 0, 0, time.UTC), weeks[0].Start)
+	assert.Equal(t, 0, weeks[0].Reviews)
+	assert.Equal(t, 3, weeks[1].Reviews)
+	assert.InDelta(t, 66.67, weeks[1].accuracy(), 0.01)
+}
+
+func TestHardestCards(t *testing.T) {
+	entries := []ReviewLogEntry{
+		{CardID: "andare", Grade: gradeGood, BoxBefore: 0, BoxAfter: 1},
+		{CardID: "andare", Grade: gradeAgain, BoxBefore: 1, BoxAfter: 0},
+		{CardID: "essere", Grade: gradeAgain, BoxBefore: 0, BoxAfter: 0},
+		{CardID: "essere", Grade: gradeAgain, BoxBefore: 0, BoxAfter: 0},
+		{CardID: "vedere", Grade: gradeGood, BoxBefore: 0, BoxAfter: 1},
+		{CardID: "dormire", Grade: gradeAgain, BoxBefore: 2, BoxAfter: 0},
+	}
+
+	assert.Equal(t, []CardStats{
+		{ID: "andare", Reviews: 2, Wrong: 1, Demotions: 1},
+		{ID: "dormire", Reviews: 1, Wrong: 1, Demotions: 1},
+		{ID: "essere", Reviews: 2, Wrong: 2},
+	}, hardestCards(entries, 10))
+
+	assert.Len(t, hardestCards(entries, 2), 2)
+}
+
+func TestForecast(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	dueDates := map[string]time.Time{
+		"andare":  {},
+		"essere":  day.AddDate(0, 0, -3),
+		"vedere":  startOfDay(day).AddDate(0, 0, 1),
+		"dormire": startOfDay(day).AddDate(0, 0, 2),
+		"parlare": startOfDay(day).AddDate(0, 0, 2),
+		"sapere":  startOfDay(day).AddDate(0, 0, 30),
+	}
+
+	assert.Equal(t, []int{2, 1, 2}, forecast(dueDates, 3))
+}
+
+func TestLeitnerDueDates(t *testing.T) {
+	day := time.Date(2020, 5, 10, 15, 30, 0, 0, time.UTC)
+	defer setNow(day)()
+
+	leitner := initLeitner(3, []Definition{defToGo, defToBe})
+	leitner.Boxes[0].Definitions = []Definition{defToGo}
+	leitner.Boxes[2].Definitions = []Definition{defToBe}
+	leitner.Reviewed["essere"] = day
+
+	assert.Equal(t, map[string]time.Time{
+		"andare": {},
+		"essere": startOfDay(day).AddDate(0, 0, 7),
+	}, leitner.dueDates())
+}
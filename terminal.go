@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Keys read in raw mode, where control keys arrive as plain input
+const (
+	keyInterrupt  = 3
+	keyEndOfInput = 4
+	keyEditCard   = 5
+	keyBackspace  = 8
+	keyTab        = '\t'
+	keyEnter      = '\r'
+	keySuspend    = 19
+	keyClearLine  = 21
+	keyUndo       = 26
+	keyEscape     = 27
+	keyDelete     = 127
+)
+
+const (
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+)
+
+// State of the terminal before it was put in raw mode, restored when the session ends
+var terminalState *term.State
+
+// Whether the alternate screen of the full-screen interface is shown
+var alternateScreen bool
+
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
+
+func enterRawMode() error {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+
+	if err != nil {
+		return err
+	}
+
+	terminalState = state
+
+	return nil
+}
+
+// Back to the screen and the line input the session started with.
+func restoreTerminal() {
+	if alternateScreen {
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		alternateScreen = false
+	}
+
+	if terminalState != nil {
+		term.Restore(int(os.Stdin.Fd()), terminalState)
+		terminalState = nil
+	}
+}
+
+// Switch to an empty screen in raw mode, the screen and the scrollback are back as they were once it's restored.
+func enterFullScreen() error {
+	if err := enterRawMode(); err != nil {
+		return err
+	}
+
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	alternateScreen = true
+
+	return nil
+}
+
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+
+	if err != nil || width <= 0 || height <= 0 {
+		return defaultTerminalWidth, defaultTerminalHeight
+	}
+
+	return width, height
+}
+
+// Wait for a single keypress. Without a terminal, e.g. when the input is piped, a line is read
+// and its first character is the key. Returns false if the input is closed or the session is interrupted.
+func readKey(input *bufio.Scanner) (rune, bool) {
+	if !isTerminal(os.Stdin) || enterRawMode() != nil {
+		return readKeyLine(input)
+	}
+
+	defer restoreTerminal()
+
+	keys, ok := readKeys(os.Stdin)
+
+	if !ok || len(keys) == 0 {
+		return 0, false
+	}
+
+	return keys[0], keys[0] != keyInterrupt && keys[0] != keyEndOfInput
+}
+
+// An empty line is Enter.
+func readKeyLine(input *bufio.Scanner) (rune, bool) {
+	if !input.Scan() {
+		return 0, false
+	}
+
+	text := strings.TrimSpace(input.Text())
+
+	if text == "" {
+		return keyEnter, true
+	}
+
+	key, _ := utf8.DecodeRuneInString(text)
+
+	return key, true
+}
+
+// Keys pressed since the last read, more than one when text is pasted. Escape sequences,
+// e.g. of arrow keys, are left out. Returns false if the input is closed.
+func readKeys(input io.Reader) ([]rune, bool) {
+	buffer := make([]byte, 256)
+	n, err := input.Read(buffer)
+
+	if err != nil || n == 0 {
+		return nil, false
+	}
+
+	if n > 1 && buffer[0] == keyEscape {
+		return []rune{}, true
+	}
+
+	return []rune(string(buffer[:n])), true
+}
@@ -0,0 +1,675 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/logrusorgru/aurora"
+)
+
+const fullScreenShortcuts = "Tab skip · Ctrl-Z undo · Ctrl-E edit card · Ctrl-S suspend card · Esc quit and save"
+
+// Rows above and below the card: the header with its rule and a blank line, the status line,
+// the rule and the shortcuts.
+const (
+	headerRows = 5
+	footerRows = 3
+)
+
+const (
+	progressBarWidth = 30
+
+	// Cards answered right this many times in a row or more share the last column of the distribution
+	maxStreakShown = 4
+)
+
+type screenAction int
+
+const (
+	actionNone screenAction = iota
+	actionSkip
+	actionUndo
+	actionEdit
+	actionSuspend
+	actionQuit
+)
+
+func shortcutAction(key rune) screenAction {
+	switch key {
+	case keyTab:
+		return actionSkip
+	case keyUndo:
+		return actionUndo
+	case keyEditCard:
+		return actionEdit
+	case keySuspend:
+		return actionSuspend
+	case keyEscape, keyInterrupt, keyEndOfInput:
+		return actionQuit
+	}
+
+	return actionNone
+}
+
+// A question on the screen and the deck as it was when the question was picked, to go back to on undo.
+type askedQuestion struct {
+	study    *StudyDeck
+	question *Question
+	snapshot *Deck
+}
+
+// The last answer, it can be undone until the deck file is edited.
+type answeredQuestion struct {
+	*askedQuestion
+
+	// As it's written to the review log
+	entry ReviewLogEntry
+}
+
+// Study session on the whole terminal screen: the card stays in place, with the progress above it
+// and the shortcuts below it.
+type FullScreen struct {
+	session *Session
+	command *CommandLine
+	input   io.Reader
+
+	// Where the signals ending the session are delivered, the editor gets Ctrl-C while it runs
+	signals chan os.Signal
+
+	// What the last shortcut did, shown under the card
+	status string
+
+	last *answeredQuestion
+}
+
+func newFullScreen(session *Session, command *CommandLine, signals chan os.Signal) *FullScreen {
+	return &FullScreen{
+		session: session,
+		command: command,
+		input:   os.Stdin,
+		signals: signals,
+	}
+}
+
+// Sessions end in endSession, which exits. Returns only if the terminal can't be used in full screen,
+// the session then goes on line by line.
+func (screen *FullScreen) run() {
+	if err := enterFullScreen(); err != nil {
+		return
+	}
+
+	var pending *askedQuestion
+
+	for {
+		asked := pending
+		pending = nil
+
+		if asked == nil {
+			asked = screen.nextQuestion()
+		}
+
+		switch screen.ask(asked) {
+		case actionSkip:
+			screen.session.mutex.Lock()
+			asked.study.deck.scheduler().skip()
+			screen.session.mutex.Unlock()
+
+			screen.status = "Skipped, it's asked again later"
+		case actionUndo:
+			pending = screen.undo(asked)
+		case actionEdit:
+			pending = screen.edit(asked)
+		case actionSuspend:
+			pending = screen.suspend(asked)
+		case actionQuit:
+			endSession(screen.session)
+		}
+	}
+}
+
+func (screen *FullScreen) nextQuestion() *askedQuestion {
+	screen.session.mutex.Lock()
+	study, question := screen.session.nextQuestion(screen.command)
+
+	var snapshot *Deck
+
+	if question != nil {
+		snapshot = study.deck.snapshot()
+	}
+
+	screen.session.mutex.Unlock()
+
+	if question == nil {
+		restoreTerminal()
+		printNothingDue(screen.session.nextDue())
+		endSession(screen.session)
+	}
+
+	return &askedQuestion{
+		study:    study,
+		question: question,
+		snapshot: snapshot,
+	}
+}
+
+// Ask the question and record the answer, unless a shortcut is used first.
+func (screen *FullScreen) ask(asked *askedQuestion) screenAction {
+	question := asked.question
+	askedAt := now()
+
+	var distractors []string
+
+	if *screen.command.mode == modeChoice {
+		distractors = choiceDistractors(asked.study.deck, question, *screen.command.choices)
+
+		if distractors == nil {
+			screen.status = typedChoiceReason(*screen.command.choices)
+		}
+	}
+
+	var answer string
+	var match Match
+	var grade Grade
+	var action screenAction
+	var lines []string
+
+	switch {
+	case *screen.command.mode == modeFlip:
+		// The key marking the answer grades it as well
+		grade, action = screen.flip(asked)
+		match = matchExact
+
+		if grade == gradeAgain {
+			match = matchWrong
+		}
+	case distractors != nil:
+		answer, match, lines, action = screen.choose(asked, distractors)
+	default:
+		answer, match, lines, action = screen.typeAnswer(asked)
+	}
+
+	if action != actionNone {
+		return action
+	}
+
+	responseTime := now().Sub(askedAt)
+
+	if grade == 0 {
+		if grade, action = screen.grade(asked, lines, suggestGrade(match)); action != actionNone {
+			return action
+		}
+	}
+
+	entry := ReviewLogEntry{
+		Time:         now(),
+		CardID:       question.Definition.ID,
+		Direction:    question.Direction,
+		Answer:       answer,
+		Result:       match,
+		Grade:        grade,
+		ResponseTime: responseTime.Milliseconds(),
+	}
+
+	screen.session.mutex.Lock()
+	entry = logAnswer(entry, screen.session, asked.study)
+	saveDeck(asked.study.deck, asked.study.path)
+	screen.session.mutex.Unlock()
+
+	screen.last = &answeredQuestion{
+		askedQuestion: asked,
+		entry:         entry,
+	}
+	screen.status = ""
+
+	return actionNone
+}
+
+// Keys pressed, or the quit action if the input is closed.
+func (screen *FullScreen) readKeys() ([]rune, screenAction) {
+	keys, ok := readKeys(screen.input)
+
+	if !ok {
+		return nil, actionQuit
+	}
+
+	return keys, actionNone
+}
+
+// Returns the answer, whether it's correct and the lines showing it, with the feedback.
+func (screen *FullScreen) typeAnswer(asked *askedQuestion) (string, Match, []string, screenAction) {
+	answer := []rune{}
+
+	for {
+		screen.draw(asked, aurora.Yellow("Answer").String(), "> "+string(answer)+"_")
+
+		keys, action := screen.readKeys()
+
+		if action != actionNone {
+			return "", matchWrong, nil, action
+		}
+
+		for _, key := range keys {
+			if action := shortcutAction(key); action != actionNone {
+				return "", matchWrong, nil, action
+			}
+
+			switch {
+			case key == keyEnter || key == '\n':
+				match, feedback := describeAnswer(string(answer), asked.question.Answer, asked.study.deck.matcher())
+				lines := []string{aurora.Yellow("Answer").String(), "> " + string(answer), "", strings.Trim(feedback, "\n")}
+
+				return string(answer), match, lines, actionNone
+			case key == keyBackspace || key == keyDelete:
+				if len(answer) > 0 {
+					answer = answer[:len(answer)-1]
+				}
+			case key == keyClearLine:
+				answer = answer[:0]
+			case unicode.IsPrint(key):
+				answer = append(answer, key)
+			}
+		}
+	}
+}
+
+// Returns the picked option, whether it's correct and the lines showing it, with the feedback.
+func (screen *FullScreen) choose(asked *askedQuestion, distractors []string) (string, Match, []string, screenAction) {
+	options, correct := buildChoices(asked.question, distractors)
+	lines := []string{aurora.Yellow("Answer").String()}
+
+	for i, option := range options {
+		lines = append(lines, fmt.Sprintf("%d) %s", i+1, strings.Replace(renderMarkup(option), "\n", "\n   ", -1)))
+	}
+
+	for {
+		screen.draw(asked, append(lines, "", fmt.Sprintf("Press 1-%d", len(options)))...)
+
+		keys, action := screen.readKeys()
+
+		if action != actionNone {
+			return "", matchWrong, nil, action
+		}
+
+		for _, key := range keys {
+			if action := shortcutAction(key); action != actionNone {
+				return "", matchWrong, nil, action
+			}
+
+			if picked := int(key - '1'); picked >= 0 && picked < len(options) {
+				match, feedback := describeChoice(options, picked, correct)
+				lines = append(lines, "", fmt.Sprintf("Picked %d", picked+1), "", strings.Trim(feedback, "\n"))
+
+				return plainText(options[picked]), match, lines, actionNone
+			}
+		}
+	}
+}
+
+// Show the answer once Enter is pressed, then read the key grading it.
+func (screen *FullScreen) flip(asked *askedQuestion) (Grade, screenAction) {
+	revealed := false
+
+	for {
+		if revealed {
+			screen.draw(asked,
+				aurora.Blue("Answer").String(),
+				renderMarkup(formatAlternatives(asked.question.Answer)),
+				"",
+				fmt.Sprintf("%s: [y] correct [n] wrong, or [1] again [2] hard [3] good [4] easy", aurora.Yellow("Grade")))
+		} else {
+			screen.draw(asked, aurora.Yellow("Press Enter to show the answer").String())
+		}
+
+		keys, action := screen.readKeys()
+
+		if action != actionNone {
+			return 0, action
+		}
+
+		for _, key := range keys {
+			if action := shortcutAction(key); action != actionNone {
+				return 0, action
+			}
+
+			if !revealed {
+				revealed = key == keyEnter || key == '\n' || key == ' '
+				continue
+			}
+
+			if grade, ok := gradeForKey(key); ok {
+				return grade, actionNone
+			}
+		}
+	}
+}
+
+// Read the grade under the answer, Enter accepts the suggested one.
+func (screen *FullScreen) grade(asked *askedQuestion, lines []string, suggested Grade) (Grade, screenAction) {
+	prompt := fmt.Sprintf("%s: [1] again [2] hard [3] good [4] easy (Enter for %s)", aurora.Yellow("Grade"), suggested)
+
+	for {
+		screen.draw(asked, append(lines, "", prompt)...)
+
+		keys, action := screen.readKeys()
+
+		if action != actionNone {
+			return 0, action
+		}
+
+		for _, key := range keys {
+			if action := shortcutAction(key); action != actionNone {
+				return 0, action
+			}
+
+			if key == keyEnter || key == '\n' {
+				return suggested, actionNone
+			}
+
+			if grade, ok := parseGrade(strings.ToLower(string(key))); ok {
+				return grade, actionNone
+			}
+		}
+	}
+}
+
+// Go back to the state before the last answer and ask its question again. The question on the screen
+// is put back, it's asked again later.
+func (screen *FullScreen) undo(asked *askedQuestion) *askedQuestion {
+	last := screen.last
+
+	if last == nil {
+		screen.status = "Nothing to undo"
+		return asked
+	}
+
+	screen.session.mutex.Lock()
+	defer screen.session.mutex.Unlock()
+
+	asked.study.deck.scheduler().skip()
+
+	study := last.study
+	*study.deck = *last.snapshot
+	study.done = false
+
+	if last.entry.Grade == gradeAgain {
+		screen.session.wrongAnswers--
+		study.wrongAnswers--
+	} else {
+		screen.session.correctAnswers--
+		study.correctAnswers--
+	}
+
+	if err := appendReviewLog(reviewLogPath(study.path), undoneEntry(last.entry)); err != nil {
+		screen.status = fmt.Sprintf("Answer undone, but it still counts in statistics: %s", err)
+	} else {
+		screen.status = "Answer undone"
+	}
+
+	saveDeck(study.deck, study.path)
+	screen.last = nil
+
+	return &askedQuestion{
+		study:    study,
+		question: last.question,
+		snapshot: study.deck.snapshot(),
+	}
+}
+
+// Open the deck file in the editor at the card's line, then take in the changes.
+func (screen *FullScreen) edit(asked *askedQuestion) *askedQuestion {
+	line := 1
+
+	if data, err := loadFile(asked.study.path); err == nil {
+		if card := findCard(data, asked.question.Definition.ID); card != nil {
+			line = card.Line
+		}
+	}
+
+	editor := strings.Fields(os.Getenv("VISUAL"))
+
+	if len(editor) == 0 {
+		editor = strings.Fields(os.Getenv("EDITOR"))
+	}
+
+	if len(editor) == 0 {
+		editor = []string{"vi"}
+	}
+
+	command := exec.Command(editor[0], append(editor[1:], fmt.Sprintf("+%d", line), asked.study.path)...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	// Ctrl-C is the editor's while it runs
+	signal.Ignore(os.Interrupt)
+	restoreTerminal()
+
+	err := command.Run()
+
+	if err := enterFullScreen(); err != nil {
+		endSession(screen.session)
+	}
+
+	signal.Notify(screen.signals, os.Interrupt)
+
+	if err != nil {
+		screen.status = fmt.Sprintf("Cannot run the editor: %s", err)
+		return asked
+	}
+
+	reconciliation, err := screen.reload(asked)
+
+	if err != nil {
+		screen.status = fmt.Sprintf("Deck file not reloaded, %s", err)
+		return asked
+	}
+
+	screen.status = fmt.Sprintf("Deck file reloaded, added: %d, updated: %d, removed: %d",
+		len(reconciliation.Added), len(reconciliation.Updated), len(reconciliation.Removed))
+
+	return nil
+}
+
+// Mark the card with @suspended in the deck file and leave it out of the session.
+func (screen *FullScreen) suspend(asked *askedQuestion) *askedQuestion {
+	if err := suspendCard(asked.study.path, asked.question.Definition.ID); err != nil {
+		screen.status = fmt.Sprintf("Cannot suspend the card, %s", err)
+		return asked
+	}
+
+	if _, err := screen.reload(asked); err != nil {
+		screen.status = fmt.Sprintf("Deck file not reloaded, %s", err)
+		return asked
+	}
+
+	screen.status = fmt.Sprintf("Suspended '%s', remove @suspended from the card in the deck file to study it again",
+		formatOneLine(asked.question.Text))
+
+	return nil
+}
+
+// Take in the deck file changed while studying. Answers before the change can't be undone any more.
+func (screen *FullScreen) reload(asked *askedQuestion) (*Reconciliation, error) {
+	data, err := loadFile(asked.study.path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	edited, err := loadDeck(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	screen.session.mutex.Lock()
+	defer screen.session.mutex.Unlock()
+
+	reconciliation := asked.study.deck.reload(edited)
+	asked.study.done = false
+	saveDeck(asked.study.deck, asked.study.path)
+	screen.last = nil
+
+	return reconciliation, nil
+}
+
+// Redraw the whole screen with the lines under the question. Lines that don't fit in the card area are cut off.
+func (screen *FullScreen) draw(asked *askedQuestion, lines ...string) {
+	width, height := terminalSize()
+
+	card := []string{aurora.Yellow("Question").String()}
+	card = append(card, strings.Split(renderMarkup(formatAlternatives(asked.question.Text)), "\n")...)
+	card = append(card, "")
+
+	for _, line := range lines {
+		card = append(card, strings.Split(line, "\n")...)
+	}
+
+	if rows := height - headerRows - footerRows; len(card) > rows {
+		if rows < 0 {
+			rows = 0
+		}
+
+		card = card[:rows]
+	}
+
+	rule := strings.Repeat("─", width)
+
+	var output strings.Builder
+
+	output.WriteString("\x1b[H\x1b[2J")
+
+	for _, line := range screen.header(asked.study, width) {
+		output.WriteString(line + "\r\n")
+	}
+
+	output.WriteString(rule + "\r\n\r\n")
+
+	for _, line := range card {
+		output.WriteString("  " + line + "\r\n")
+	}
+
+	fmt.Fprintf(&output, "\x1b[%d;1H%s\r\n%s\r\n%s", height-footerRows+1, aurora.Yellow(screen.status), rule, fullScreenShortcuts)
+
+	fmt.Print(output.String())
+}
+
+// Deck name and session score, progress through the stage and the cards per box.
+func (screen *FullScreen) header(study *StudyDeck, width int) []string {
+	title := "repetition · " + deckName(study.path)
+	score := fmt.Sprintf("%d correct, %d wrong", screen.session.correctAnswers, screen.session.wrongAnswers)
+
+	if total := screen.session.correctAnswers + screen.session.wrongAnswers; total != 0 {
+		score += fmt.Sprintf(" (%0.0f%%)", float64(screen.session.correctAnswers)/float64(total)*100)
+	}
+
+	padding := width - utf8.RuneCountInString(title) - utf8.RuneCountInString(score)
+
+	if padding < 1 {
+		padding = 1
+	}
+
+	label, done, total := stageProgress(study)
+	levelLabel, counts := levelDistribution(study.deck)
+	levels := []string{}
+
+	for i, count := range counts {
+		level := fmt.Sprint(i)
+
+		switch {
+		case levelLabel == "Boxes":
+			level = fmt.Sprint(i + 1)
+		case i == len(counts)-1:
+			level += "+"
+		}
+
+		levels = append(levels, fmt.Sprintf("%s: %d", level, count))
+	}
+
+	return []string{
+		aurora.Bold(title).String() + strings.Repeat(" ", padding) + score,
+		fmt.Sprintf("%-12s %s %d/%d", label, progressBar(done, total, progressBarWidth), done, total),
+		fmt.Sprintf("%-12s %s", levelLabel, strings.Join(levels, "  ")),
+	}
+}
+
+// Progress through the current Leitner stage, or through the cards due today with other algorithms.
+// Returns its name, the number of cards answered and the number of cards in it.
+func stageProgress(study *StudyDeck) (string, int, int) {
+	scheduler := study.deck.scheduler()
+
+	if leitner, ok := scheduler.(*Leitner); ok {
+		done := len(leitner.movements)
+		remaining := 0
+
+		for _, box := range leitner.BoxesInCurrentStage {
+			for _, def := range box.Definitions {
+				if !def.Suspended && leitner.isDue(def.ID, box.BoxNumber) {
+					remaining++
+				}
+			}
+		}
+
+		if leitner.CurrentDefinition != nil {
+			remaining++
+		}
+
+		return fmt.Sprintf("Stage %d/%d", leitner.Stage+1, leitner.BoxCount), done, done + remaining
+	}
+
+	done := study.correctAnswers + study.wrongAnswers
+	remaining := 0
+	dueDates := scheduler.dueDates()
+
+	for _, def := range study.deck.Definitions {
+		if !def.Suspended && !dueDates[def.ID].After(now()) {
+			remaining++
+		}
+	}
+
+	return "Due today", done, done + remaining
+}
+
+// Cards per Leitner box, or per number of right answers in a row with other algorithms.
+func levelDistribution(deck *Deck) (string, []int) {
+	scheduler := deck.scheduler()
+	label := "Streaks"
+	counts := make([]int, maxStreakShown+1)
+
+	if leitner, ok := scheduler.(*Leitner); ok {
+		label = "Boxes"
+		counts = make([]int, leitner.BoxCount)
+	}
+
+	for _, def := range deck.Definitions {
+		level := scheduler.level(def.ID)
+
+		if level < 0 {
+			continue
+		}
+
+		if level >= len(counts) {
+			level = len(counts) - 1
+		}
+
+		counts[level]++
+	}
+
+	return label, counts
+}
+
+func progressBar(done int, total int, width int) string {
+	filled := 0
+
+	if total > 0 {
+		filled = done * width / total
+	}
+
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
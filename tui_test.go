@@ -0,0 +1,198 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getFullScreen(input string) *FullScreen {
+	session := getSession()
+	typed := modeTyped
+	choices := minChoices
+
+	command := getCommand("standard")
+	command.mode = &typed
+	command.choices = &choices
+
+	return &FullScreen{
+		session: session,
+		command: command,
+		input:   strings.NewReader(input),
+	}
+}
+
+func getAskedQuestion(deck *Deck) *askedQuestion {
+	def := deck.scheduler().next()
+
+	return &askedQuestion{
+		study:    &StudyDeck{path: "italian.deck", deck: deck},
+		question: &Question{Definition: *def, Text: def.From, Answer: def.To, Direction: directionForward},
+		snapshot: deck.snapshot(),
+	}
+}
+
+func TestShortcutAction(t *testing.T) {
+	assert.Equal(t, actionSkip, shortcutAction(keyTab))
+	assert.Equal(t, actionUndo, shortcutAction(keyUndo))
+	assert.Equal(t, actionEdit, shortcutAction(keyEditCard))
+	assert.Equal(t, actionSuspend, shortcutAction(keySuspend))
+	assert.Equal(t, actionQuit, shortcutAction(keyEscape))
+	assert.Equal(t, actionQuit, shortcutAction(keyInterrupt))
+	assert.Equal(t, actionNone, shortcutAction('a'))
+}
+
+func TestReadKeys(t *testing.T) {
+	keys, ok := readKeys(strings.NewReader("città"))
+
+	assert.True(t, ok)
+	assert.Equal(t, []rune("città"), keys)
+
+	// An arrow key
+	keys, ok = readKeys(strings.NewReader("\x1b[A"))
+
+	assert.True(t, ok)
+	assert.Empty(t, keys)
+
+	_, ok = readKeys(strings.NewReader(""))
+
+	assert.False(t, ok)
+}
+
+func TestProgressBar(t *testing.T) {
+	assert.Equal(t, "[##........]", progressBar(1, 4, 10))
+	assert.Equal(t, "[##########]", progressBar(4, 4, 10))
+	assert.Equal(t, "[..........]", progressBar(0, 0, 10))
+}
+
+func TestStageProgress(t *testing.T) {
+	deck, _ := loadDeck("[(andare) (to go)] [(essere) (to be)] [(vedere) (to see) @suspended]")
+	study := &StudyDeck{deck: deck}
+
+	deck.scheduler().next()
+	deck.scheduler().record(gradeGood)
+	deck.scheduler().next()
+
+	label, done, total := stageProgress(study)
+
+	assert.Equal(t, "Stage 1/3", label)
+	assert.Equal(t, 1, done)
+	assert.Equal(t, 2, total)
+
+	deck.useAlgorithm(algorithmSM2)
+	study.correctAnswers = 1
+
+	label, done, total = stageProgress(study)
+
+	assert.Equal(t, "Due today", label)
+	assert.Equal(t, 1, done)
+	assert.Equal(t, 3, total)
+}
+
+func TestLevelDistribution(t *testing.T) {
+	deck, _ := loadDeck("@boxes 4 [(andare) (to go)] [(essere) (to be)]")
+
+	deck.scheduler().next()
+	deck.scheduler().record(gradeEasy)
+
+	label, counts := levelDistribution(deck)
+
+	assert.Equal(t, "Boxes", label)
+	assert.Equal(t, []int{1, 0, 1, 0}, counts)
+
+	deck.useAlgorithm(algorithmFSRS)
+
+	label, counts = levelDistribution(deck)
+
+	assert.Equal(t, "Streaks", label)
+	assert.Equal(t, []int{2, 0, 0, 0, 0}, counts)
+}
+
+func TestFullScreenTypeAnswer(t *testing.T) {
+	deck, _ := loadDeck("[(andare) (to go)]")
+	asked := getAskedQuestion(deck)
+
+	answer, match, lines, action := getFullScreen("to gx\x7fo\r").typeAnswer(asked)
+
+	assert.Equal(t, actionNone, action)
+	assert.Equal(t, "to go", answer)
+	assert.Equal(t, matchExact, match)
+	assert.Contains(t, lines, "> to go")
+
+	_, _, _, action = getFullScreen("to\t").typeAnswer(asked)
+
+	assert.Equal(t, actionSkip, action)
+}
+
+func TestFullScreenChoice_too_few_answers(t *testing.T) {
+	deck, _ := loadDeck("[(andare) (to go)] [(essere) (to be)]")
+	screen := getFullScreen("\x1b")
+	choice := modeChoice
+	screen.command.mode = &choice
+
+	assert.Equal(t, actionQuit, screen.ask(getAskedQuestion(deck)))
+	assert.Equal(t, "Not enough other answers in the deck for 4 options, type the answer", screen.status)
+}
+
+func TestFullScreenGrade(t *testing.T) {
+	deck, _ := loadDeck("[(andare) (to go)]")
+	asked := getAskedQuestion(deck)
+
+	grade, action := getFullScreen("x\r").grade(asked, nil, gradeHard)
+
+	assert.Equal(t, actionNone, action)
+	assert.Equal(t, gradeHard, grade)
+
+	grade, _ = getFullScreen("e").grade(asked, nil, gradeHard)
+
+	assert.Equal(t, gradeEasy, grade)
+
+	_, action = getFullScreen("").grade(asked, nil, gradeHard)
+
+	assert.Equal(t, actionQuit, action)
+}
+
+func TestFullScreenUndo(t *testing.T) {
+	dir, cleanup := getTempDir(t)
+	defer cleanup()
+
+	deck, _ := loadDeck("[@id andare (andare) (to go)] [@id ire (ire) (to go)]")
+	screen := getFullScreen("")
+
+	// Keys arrive one at a time, as they're typed
+	screen.input = iotest.OneByteReader(strings.NewReader("to go\r\r"))
+
+	asked := getAskedQuestion(deck)
+	asked.study.path = filepath.Join(dir, "italian.deck")
+	id := asked.question.Definition.ID
+
+	assert.Equal(t, actionNone, screen.ask(asked))
+	assert.Equal(t, 1, screen.session.correctAnswers)
+	assert.Equal(t, 1, deck.Leitner.level(id))
+
+	entries, _ := loadReviewLog(reviewLogPath(asked.study.path))
+	assert.Len(t, entries, 1)
+
+	next := getAskedQuestion(deck)
+	next.study = asked.study
+	undone := screen.undo(next)
+
+	assert.Equal(t, asked.question, undone.question)
+	assert.Equal(t, 0, screen.session.correctAnswers)
+	assert.Equal(t, 0, asked.study.correctAnswers)
+	assert.Equal(t, 0, deck.Leitner.level(id))
+	assert.Nil(t, screen.last)
+
+	// The answer stays in the log, with an entry undoing it
+	entries, _ = loadReviewLog(reviewLogPath(asked.study.path))
+	assert.Len(t, entries, 2)
+	assert.Equal(t, matchUndone, entries[1].Result)
+	assert.Empty(t, withoutUndone(entries))
+
+	// Only the last answer can be undone
+	assert.Equal(t, undone, screen.undo(undone))
+	assert.Equal(t, "Nothing to undo", screen.status)
+}